@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/money"
 	"math/rand"
 	"net/http"
 	"os"
@@ -11,14 +14,6 @@ import (
 	"time"
 )
 
-// Event представляет событие пользователя
-type Event struct {
-	Timestamp  time.Time
-	UserID     uint64
-	EventName  string
-	Parameters string
-}
-
 // randomInt генерирует случайное целое число в диапазоне [min, max]
 func randomInt(min, max int) int {
 	return min + rand.Intn(max-min+1)
@@ -31,87 +26,135 @@ func randomPlatform() string {
 }
 
 // randomPaymentMethod возвращает случайный способ оплаты
-func randomPaymentMethod() string {
-	methods := []string{"wallet", "card", "cash"}
-	return methods[randomInt(0, 2)]
+func randomPaymentMethod() models.PaymentMethod {
+	return models.PaymentMethods[randomInt(0, len(models.PaymentMethods)-1)]
 }
 
-// generateUserEvents генерирует события для одного пользователя
-func generateUserEvents(userID uint64, startDate time.Time) []Event {
-	events := []Event{}
-	currentDate := startDate
+// randomOrderID возвращает номер заказа пользователя. Случайная часть не даёт заказам
+// из разных запусков генератора совпасть: иначе отмена задела бы старую покупку.
+func randomOrderID(userID uint64) string {
+	return fmt.Sprintf("%d-%08x", userID, rand.Uint32())
+}
+
+// randomChoice возвращает случайный элемент списка
+func randomChoice(values []string) string {
+	return values[randomInt(0, len(values)-1)]
+}
+
+// cancelReasons и refundReasons - причины отмены заказа до получения и возврата после
+var (
+	cancelReasons = []string{"changed_mind", "found_cheaper", "delivery_too_long"}
+	refundReasons = []string{"size", "defect", "not_as_described"}
+)
 
-	// Событие: open_app
-	openAppParams := fmt.Sprintf(`{"platform": "%s", "region": "RU"}`, randomPlatform())
-	events = append(events, Event{
-		Timestamp:  currentDate,
+// orderCancellation с небольшой вероятностью отменяет или возвращает заказ покупки:
+// отмена приходит через несколько минут, возврат - через 1-14 дней после получения.
+// Возвращает nil, если заказ остался в силе.
+func orderCancellation(buy *models.BuyParams, buyTime time.Time) (models.Params, time.Time) {
+	switch r := rand.Float64(); {
+	case r < 0.05:
+		at := buyTime.Add(time.Minute * time.Duration(randomInt(1, 30)))
+		return &models.CancelParams{OrderID: buy.OrderID, Reason: randomChoice(cancelReasons)}, at
+	case r < 0.13:
+		at := buyTime.Add(24*time.Hour*time.Duration(randomInt(1, 14)) + time.Minute*time.Duration(randomInt(0, 24*60)))
+		return &models.RefundParams{OrderID: buy.OrderID, Reason: randomChoice(refundReasons)}, at
+	}
+	return nil, time.Time{}
+}
+
+// newEvent проверяет параметры строгими правилами модели и сериализует их в колонку parameters
+func newEvent(timestamp time.Time, userID uint64, params models.Params) (models.Event, error) {
+	if err := params.Validate(); err != nil {
+		return models.Event{}, fmt.Errorf("invalid %s event: %w", params.EventName(), err)
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return models.Event{}, err
+	}
+	return models.Event{
+		Timestamp:  timestamp,
 		UserID:     userID,
-		EventName:  "open_app",
-		Parameters: openAppParams,
-	})
+		EventName:  params.EventName(),
+		Parameters: string(raw),
+	}, nil
+}
 
-	// Случайный интервал
-	currentDate = currentDate.Add(time.Second * time.Duration(randomInt(1, 60)))
+// generateUserEvents генерирует события для одного пользователя
+func generateUserEvents(userID uint64, startDate time.Time) ([]models.Event, error) {
+	params := []models.Params{&models.OpenAppParams{Platform: randomPlatform(), Region: "RU"}}
 
 	// С вероятностью 80% генерируем событие "cart"
 	if rand.Float64() < 0.8 {
-		cartParams := fmt.Sprintf(`{"total_amount": %d, "currency": "RUB", "n_goods": %d, "goods_list": "..."}`, randomInt(100, 10000), randomInt(1, 10))
-		events = append(events, Event{
-			Timestamp:  currentDate,
-			UserID:     userID,
-			EventName:  "cart",
-			Parameters: cartParams,
+		params = append(params, &models.CartParams{
+			TotalAmount: money.Amount(randomInt(100_00, 10000_00)), // от 100 до 10 000 ₽ с копейками
+			Currency:    models.DefaultCurrency,
+			NGoods:      randomInt(1, 10),
+			GoodsList:   "...",
 		})
 
-		// Интервал
-		currentDate = currentDate.Add(time.Second * time.Duration(randomInt(1, 60)))
-
 		// С вероятностью 70% генерируем событие "payment_methods"
 		if rand.Float64() < 0.7 {
-			paymentMethodsParams := fmt.Sprintf(`{"default_method": "%s"}`, randomPaymentMethod())
-			events = append(events, Event{
-				Timestamp:  currentDate,
-				UserID:     userID,
-				EventName:  "payment_methods",
-				Parameters: paymentMethodsParams,
-			})
-
-			// Интервал
-			currentDate = currentDate.Add(time.Second * time.Duration(randomInt(1, 60)))
+			params = append(params, &models.PaymentMethodsParams{DefaultMethod: randomPaymentMethod()})
 
 			// С вероятностью 60% генерируем событие "buy"
 			if rand.Float64() < 0.6 {
-				buyParams := fmt.Sprintf(`{"amount": %d, "currency": "RUB", "n_goods": %d, "payment_method": "%s"}`, randomInt(100, 10000), randomInt(1, 10), randomPaymentMethod())
-				events = append(events, Event{
-					Timestamp:  currentDate,
-					UserID:     userID,
-					EventName:  "buy",
-					Parameters: buyParams,
+				params = append(params, &models.BuyParams{
+					Amount:        money.Amount(randomInt(100_00, 10000_00)),
+					Currency:      models.DefaultCurrency,
+					NGoods:        randomInt(1, 10),
+					PaymentMethod: randomPaymentMethod(),
+					OrderID:       randomOrderID(userID),
 				})
 			}
 		}
 	}
 
-	return events
+	events := make([]models.Event, 0, len(params)+1)
+	currentDate := startDate
+	for _, p := range params {
+		event, err := newEvent(currentDate, userID, p)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+
+		// Часть заказов отменяют или возвращают
+		if buy, ok := p.(*models.BuyParams); ok {
+			if cancel, at := orderCancellation(buy, currentDate); cancel != nil {
+				event, err := newEvent(at, userID, cancel)
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, event)
+			}
+		}
+
+		// Случайный интервал до следующего события
+		currentDate = currentDate.Add(time.Second * time.Duration(randomInt(1, 60)))
+	}
+	return events, nil
 }
 
 // generateMockData генерирует моковые данные для нескольких пользователей
-func generateMockData(numUsers int, startDate time.Time) []Event {
-	allEvents := []Event{}
+func generateMockData(numUsers int, startDate time.Time) ([]models.Event, error) {
+	allEvents := []models.Event{}
 	userID := uint64(1000) // Начальный ID пользователя
 
 	for i := 0; i < numUsers; i++ {
-		userEvents := generateUserEvents(userID, startDate)
+		userEvents, err := generateUserEvents(userID, startDate)
+		if err != nil {
+			return nil, err
+		}
 		allEvents = append(allEvents, userEvents...)
 		userID++
 		startDate = startDate.Add(time.Minute * time.Duration(randomInt(1, 10)))
 	}
 
-	return allEvents
+	return allEvents, nil
 }
 
 // insertIntoClickHouse вставляет события в ClickHouse
-func insertIntoClickHouse(events []Event) error {
+func insertIntoClickHouse(events []models.Event) error {
 	conn, err := clickhouse.Open(&clickhouse.Options{
 		Addr: []string{"localhost:9000"},
 		Auth: clickhouse.Auth{
@@ -135,7 +178,7 @@ func insertIntoClickHouse(events []Event) error {
 		err := batch.Append(
 			event.Timestamp,
 			event.UserID,
-			event.EventName,
+			string(event.EventName),
 			event.Parameters,
 		)
 		if err != nil {
@@ -161,7 +204,11 @@ func generateMockDataHandler(w http.ResponseWriter, r *http.Request) {
 		startDate = time.Now()
 	}
 
-	events := generateMockData(numUsers, startDate)
+	events, err := generateMockData(numUsers, startDate)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate mock data: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	// Вставка в ClickHouse
 	err = insertIntoClickHouse(events)
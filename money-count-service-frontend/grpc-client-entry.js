@@ -7,5 +7,7 @@ window.proto = window.proto || {};
 window.proto.money_service = {
     GetSavingsRequest: proto.GetSavingsRequest,
     GetSavingsResponse: proto.GetSavingsResponse,
+    Money: proto.Money,
+    CurrencySavings: proto.CurrencySavings,
     MoneyServiceClient: service.MoneyServiceClient,
 };
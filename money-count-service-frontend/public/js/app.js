@@ -75,7 +75,7 @@ function getSavings(userId) {
             const result = {
                 status: statusName,
                 statusCode: status,
-                totalSavings: response.getTotalSavings ? response.getTotalSavings() : 0,
+                totalSavings: savingsFromResponse(response),
                 currency: response.getCurrency ? response.getCurrency() : 'RUB',
                 totalPurchases: response.getTotalPurchases ? response.getTotalPurchases() : 0,
                 wbCardPurchases: response.getWbCardPurchases ? response.getWbCardPurchases() : 0,
@@ -93,6 +93,22 @@ function getSavings(userId) {
     });
 }
 
+// Экономия из поля savings (Money) без ошибок округления double;
+// total_savings - запасной вариант для сервера без этого поля
+function savingsFromResponse(response) {
+    if (response.hasSavings && response.hasSavings()) {
+        const money = response.getSavings();
+        const kopecks = money.getUnits() * 100 + Math.round(money.getNanos() / 1e7);
+        return kopecks / 100;
+    }
+    return response.getTotalSavings ? response.getTotalSavings() : 0;
+}
+
+// Сумма всегда с двумя знаками после запятой, как в бухгалтерии
+function formatMoney(value) {
+    return value.toLocaleString('ru-RU', { minimumFractionDigits: 2, maximumFractionDigits: 2 });
+}
+
 function getStatusName(statusCode) {
     const statusMap = {
         0: 'OK',
@@ -101,7 +117,9 @@ function getStatusName(statusCode) {
         3: 'DB_ERROR',
         4: 'INVALID_REQUEST',
         5: 'UNAUTHORIZED',
-        6: 'UNKNOWN_ERROR'
+        6: 'UNKNOWN_ERROR',
+        7: 'TIMEOUT',
+        8: 'RATES_UNAVAILABLE'
     };
     return statusMap[statusCode] || 'UNKNOWN_STATUS';
 }
@@ -114,7 +132,9 @@ function getErrorMessage(status, serverMessage) {
             'ка базы данных',
         'INVALID_REQUEST': 'Некорректный запрос',
         'UNAUTHORIZED': 'Нет доступа к данным пользователя',
-        'UNKNOWN_ERROR': 'Неизвестная ошибка'
+        'UNKNOWN_ERROR': 'Неизвестная ошибка',
+        'TIMEOUT': 'Сервер не успел ответить, попробуйте ещё раз',
+        'RATES_UNAVAILABLE': 'Нет курса валюты для пересчёта экономии'
     };
 
     const baseMessage = errorMessages[status] || 'Неизвестная ошибка';
@@ -126,17 +146,17 @@ function showResult(data) {
     const savingsValue = data.totalSavings;
 
     if (savingsValue > 0) {
-        savingsAmount.textContent = `+${savingsValue.toLocaleString()} ${data.currency}`;
+        savingsAmount.textContent = `+${formatMoney(savingsValue)} ${data.currency}`;
         savingsAmount.style.color = '#22c55e';
         savingsAmount.classList.add('positive');
         savingsAmount.classList.remove('negative');
     } else if (savingsValue < 0) {
-        savingsAmount.textContent = `${savingsValue.toLocaleString()} ${data.currency}`;
+        savingsAmount.textContent = `${formatMoney(savingsValue)} ${data.currency}`;
         savingsAmount.style.color = '#ef4444';
         savingsAmount.classList.add('negative');
         savingsAmount.classList.remove('positive');
     } else {
-        savingsAmount.textContent = `${savingsValue.toLocaleString()} ${data.currency}`;
+        savingsAmount.textContent = `${formatMoney(savingsValue)} ${data.currency}`;
         savingsAmount.style.color = '#6b7280';
         savingsAmount.classList.remove('positive', 'negative');
     }
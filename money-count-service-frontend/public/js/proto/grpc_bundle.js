@@ -166,6 +166,8 @@ var global =
 goog.exportSymbol('proto.money_service.GetSavingsRequest', null, global);
 goog.exportSymbol('proto.money_service.GetSavingsResponse', null, global);
 goog.exportSymbol('proto.money_service.GetSavingsResponse.Status', null, global);
+goog.exportSymbol('proto.money_service.Money', null, global);
+goog.exportSymbol('proto.money_service.CurrencySavings', null, global);
 /**
  * Generated by JsPbCodeGenerator.
  * @param {Array=} opt_data Optional initial data array, typically from a
@@ -198,7 +200,7 @@ if (goog.DEBUG && !COMPILED) {
  * @constructor
  */
 proto.money_service.GetSavingsResponse = function(opt_data) {
-  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+  jspb.Message.initialize(this, opt_data, 0, -1, proto.money_service.GetSavingsResponse.repeatedFields_, null);
 };
 goog.inherits(proto.money_service.GetSavingsResponse, jspb.Message);
 if (goog.DEBUG && !COMPILED) {
@@ -208,6 +210,48 @@ if (goog.DEBUG && !COMPILED) {
    */
   proto.money_service.GetSavingsResponse.displayName = 'proto.money_service.GetSavingsResponse';
 }
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.money_service.Money = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+};
+goog.inherits(proto.money_service.Money, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  /**
+   * @public
+   * @override
+   */
+  proto.money_service.Money.displayName = 'proto.money_service.Money';
+}
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.money_service.CurrencySavings = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+};
+goog.inherits(proto.money_service.CurrencySavings, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  /**
+   * @public
+   * @override
+   */
+  proto.money_service.CurrencySavings.displayName = 'proto.money_service.CurrencySavings';
+}
 
 
 
@@ -240,7 +284,8 @@ proto.money_service.GetSavingsRequest.prototype.toObject = function(opt_includeI
  */
 proto.money_service.GetSavingsRequest.toObject = function(includeInstance, msg) {
   var f, obj = {
-userId: jspb.Message.getFieldWithDefault(msg, 1, 0)
+userId: jspb.Message.getFieldWithDefault(msg, 1, 0),
+currency: jspb.Message.getFieldWithDefault(msg, 5, "")
   };
 
   if (includeInstance) {
@@ -281,6 +326,10 @@ proto.money_service.GetSavingsRequest.deserializeBinaryFromReader = function(msg
       var value = /** @type {number} */ (reader.readInt64());
       msg.setUserId(value);
       break;
+    case 5:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setCurrency(value);
+      break;
     default:
       reader.skipField();
       break;
@@ -317,6 +366,13 @@ proto.money_service.GetSavingsRequest.serializeBinaryToWriter = function(message
       f
     );
   }
+  f = message.getCurrency();
+  if (f.length > 0) {
+    writer.writeString(
+      5,
+      f
+    );
+  }
 };
 
 
@@ -338,6 +394,32 @@ proto.money_service.GetSavingsRequest.prototype.setUserId = function(value) {
 };
 
 
+/**
+ * optional string currency = 5;
+ * @return {string}
+ */
+proto.money_service.GetSavingsRequest.prototype.getCurrency = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 5, ""));
+};
+
+
+/**
+ * @param {string} value
+ * @return {!proto.money_service.GetSavingsRequest} returns this
+ */
+proto.money_service.GetSavingsRequest.prototype.setCurrency = function(value) {
+  return jspb.Message.setProto3StringField(this, 5, value);
+};
+
+
+
+
+/**
+ * List of repeated fields within this message type.
+ * @private {!Array<number>}
+ * @const
+ */
+proto.money_service.GetSavingsResponse.repeatedFields_ = [10];
 
 
 
@@ -375,7 +457,10 @@ totalSavings: jspb.Message.getFloatingPointFieldWithDefault(msg, 2, 0.0),
 currency: jspb.Message.getFieldWithDefault(msg, 3, ""),
 totalPurchases: jspb.Message.getFieldWithDefault(msg, 4, 0),
 wbCardPurchases: jspb.Message.getFieldWithDefault(msg, 5, 0),
-message: jspb.Message.getFieldWithDefault(msg, 6, "")
+message: jspb.Message.getFieldWithDefault(msg, 6, ""),
+savings: (f = msg.getSavings()) && proto.money_service.Money.toObject(includeInstance, f),
+byCurrencyList: jspb.Message.toObjectList(msg.getByCurrencyList(),
+    proto.money_service.CurrencySavings.toObject, includeInstance)
   };
 
   if (includeInstance) {
@@ -436,6 +521,16 @@ proto.money_service.GetSavingsResponse.deserializeBinaryFromReader = function(ms
       var value = /** @type {string} */ (reader.readString());
       msg.setMessage(value);
       break;
+    case 9:
+      var value = new proto.money_service.Money;
+      reader.readMessage(value,proto.money_service.Money.deserializeBinaryFromReader);
+      msg.setSavings(value);
+      break;
+    case 10:
+      var value = new proto.money_service.CurrencySavings;
+      reader.readMessage(value,proto.money_service.CurrencySavings.deserializeBinaryFromReader);
+      msg.addByCurrency(value);
+      break;
     default:
       reader.skipField();
       break;
@@ -507,6 +602,22 @@ proto.money_service.GetSavingsResponse.serializeBinaryToWriter = function(messag
       f
     );
   }
+  f = message.getSavings();
+  if (f != null) {
+    writer.writeMessage(
+      9,
+      f,
+      proto.money_service.Money.serializeBinaryToWriter
+    );
+  }
+  f = message.getByCurrencyList();
+  if (f.length > 0) {
+    writer.writeRepeatedMessage(
+      10,
+      f,
+      proto.money_service.CurrencySavings.serializeBinaryToWriter
+    );
+  }
 };
 
 
@@ -520,7 +631,9 @@ proto.money_service.GetSavingsResponse.Status = {
   DB_ERROR: 3,
   INVALID_REQUEST: 4,
   UNAUTHORIZED: 5,
-  UNKNOWN_ERROR: 6
+  UNKNOWN_ERROR: 6,
+  TIMEOUT: 7,
+  RATES_UNAVAILABLE: 8
 };
 
 /**
@@ -631,6 +744,532 @@ proto.money_service.GetSavingsResponse.prototype.setMessage = function(value) {
 };
 
 
+/**
+ * optional Money savings = 9;
+ * @return {?proto.money_service.Money}
+ */
+proto.money_service.GetSavingsResponse.prototype.getSavings = function() {
+  return /** @type{?proto.money_service.Money} */ (
+    jspb.Message.getWrapperField(this, proto.money_service.Money, 9));
+};
+
+
+/**
+ * @param {?proto.money_service.Money|undefined} value
+ * @return {!proto.money_service.GetSavingsResponse} returns this
+*/
+proto.money_service.GetSavingsResponse.prototype.setSavings = function(value) {
+  return jspb.Message.setWrapperField(this, 9, value);
+};
+
+
+/**
+ * Clears the message field making it undefined.
+ * @return {!proto.money_service.GetSavingsResponse} returns this
+ */
+proto.money_service.GetSavingsResponse.prototype.clearSavings = function() {
+  return this.setSavings(undefined);
+};
+
+
+/**
+ * Returns whether this field is set.
+ * @return {boolean}
+ */
+proto.money_service.GetSavingsResponse.prototype.hasSavings = function() {
+  return jspb.Message.getField(this, 9) != null;
+};
+
+
+/**
+ * repeated CurrencySavings by_currency = 10;
+ * @return {!Array<!proto.money_service.CurrencySavings>}
+ */
+proto.money_service.GetSavingsResponse.prototype.getByCurrencyList = function() {
+  return /** @type{!Array<!proto.money_service.CurrencySavings>} */ (
+    jspb.Message.getRepeatedWrapperField(this, proto.money_service.CurrencySavings, 10));
+};
+
+
+/**
+ * @param {!Array<!proto.money_service.CurrencySavings>} value
+ * @return {!proto.money_service.GetSavingsResponse} returns this
+*/
+proto.money_service.GetSavingsResponse.prototype.setByCurrencyList = function(value) {
+  return jspb.Message.setRepeatedWrapperField(this, 10, value);
+};
+
+
+/**
+ * @param {!proto.money_service.CurrencySavings=} opt_value
+ * @param {number=} opt_index
+ * @return {!proto.money_service.CurrencySavings}
+ */
+proto.money_service.GetSavingsResponse.prototype.addByCurrency = function(opt_value, opt_index) {
+  return jspb.Message.addToRepeatedWrapperField(this, 10, opt_value, proto.money_service.CurrencySavings, opt_index);
+};
+
+
+/**
+ * Clears the list making it empty but non-null.
+ * @return {!proto.money_service.GetSavingsResponse} returns this
+ */
+proto.money_service.GetSavingsResponse.prototype.clearByCurrencyList = function() {
+  return this.setByCurrencyList([]);
+};
+
+
+
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * Optional fields that are not set will be set to undefined.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     net/proto2/compiler/js/internal/generator.cc#kKeyword.
+ * @param {boolean=} opt_includeInstance Deprecated. whether to include the
+ *     JSPB instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.money_service.Money.prototype.toObject = function(opt_includeInstance) {
+  return proto.money_service.Money.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Deprecated. Whether to include
+ *     the JSPB instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.money_service.Money} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.money_service.Money.toObject = function(includeInstance, msg) {
+  var f, obj = {
+units: jspb.Message.getFieldWithDefault(msg, 1, 0),
+nanos: jspb.Message.getFieldWithDefault(msg, 2, 0),
+currency: jspb.Message.getFieldWithDefault(msg, 3, "")
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.money_service.Money}
+ */
+proto.money_service.Money.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.money_service.Money;
+  return proto.money_service.Money.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.money_service.Money} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.money_service.Money}
+ */
+proto.money_service.Money.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = /** @type {number} */ (reader.readInt64());
+      msg.setUnits(value);
+      break;
+    case 2:
+      var value = /** @type {number} */ (reader.readInt32());
+      msg.setNanos(value);
+      break;
+    case 3:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setCurrency(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.money_service.Money.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.money_service.Money.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.money_service.Money} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.money_service.Money.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getUnits();
+  if (f !== 0) {
+    writer.writeInt64(
+      1,
+      f
+    );
+  }
+  f = message.getNanos();
+  if (f !== 0) {
+    writer.writeInt32(
+      2,
+      f
+    );
+  }
+  f = message.getCurrency();
+  if (f.length > 0) {
+    writer.writeString(
+      3,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional int64 units = 1;
+ * @return {number}
+ */
+proto.money_service.Money.prototype.getUnits = function() {
+  return /** @type {number} */ (jspb.Message.getFieldWithDefault(this, 1, 0));
+};
+
+
+/**
+ * @param {number} value
+ * @return {!proto.money_service.Money} returns this
+ */
+proto.money_service.Money.prototype.setUnits = function(value) {
+  return jspb.Message.setProto3IntField(this, 1, value);
+};
+
+
+/**
+ * optional int32 nanos = 2;
+ * @return {number}
+ */
+proto.money_service.Money.prototype.getNanos = function() {
+  return /** @type {number} */ (jspb.Message.getFieldWithDefault(this, 2, 0));
+};
+
+
+/**
+ * @param {number} value
+ * @return {!proto.money_service.Money} returns this
+ */
+proto.money_service.Money.prototype.setNanos = function(value) {
+  return jspb.Message.setProto3IntField(this, 2, value);
+};
+
+
+/**
+ * optional string currency = 3;
+ * @return {string}
+ */
+proto.money_service.Money.prototype.getCurrency = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 3, ""));
+};
+
+
+/**
+ * @param {string} value
+ * @return {!proto.money_service.Money} returns this
+ */
+proto.money_service.Money.prototype.setCurrency = function(value) {
+  return jspb.Message.setProto3StringField(this, 3, value);
+};
+
+
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * Optional fields that are not set will be set to undefined.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     net/proto2/compiler/js/internal/generator.cc#kKeyword.
+ * @param {boolean=} opt_includeInstance Deprecated. whether to include the
+ *     JSPB instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.money_service.CurrencySavings.prototype.toObject = function(opt_includeInstance) {
+  return proto.money_service.CurrencySavings.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Deprecated. Whether to include
+ *     the JSPB instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.money_service.CurrencySavings} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.money_service.CurrencySavings.toObject = function(includeInstance, msg) {
+  var f, obj = {
+currency: jspb.Message.getFieldWithDefault(msg, 1, ""),
+purchases: jspb.Message.getFieldWithDefault(msg, 2, 0),
+savings: (f = msg.getSavings()) && proto.money_service.Money.toObject(includeInstance, f),
+convertedSavings: (f = msg.getConvertedSavings()) && proto.money_service.Money.toObject(includeInstance, f)
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.money_service.CurrencySavings}
+ */
+proto.money_service.CurrencySavings.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.money_service.CurrencySavings;
+  return proto.money_service.CurrencySavings.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.money_service.CurrencySavings} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.money_service.CurrencySavings}
+ */
+proto.money_service.CurrencySavings.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setCurrency(value);
+      break;
+    case 2:
+      var value = /** @type {number} */ (reader.readInt32());
+      msg.setPurchases(value);
+      break;
+    case 3:
+      var value = new proto.money_service.Money;
+      reader.readMessage(value,proto.money_service.Money.deserializeBinaryFromReader);
+      msg.setSavings(value);
+      break;
+    case 4:
+      var value = new proto.money_service.Money;
+      reader.readMessage(value,proto.money_service.Money.deserializeBinaryFromReader);
+      msg.setConvertedSavings(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.money_service.CurrencySavings.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.money_service.CurrencySavings.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.money_service.CurrencySavings} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.money_service.CurrencySavings.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getCurrency();
+  if (f.length > 0) {
+    writer.writeString(
+      1,
+      f
+    );
+  }
+  f = message.getPurchases();
+  if (f !== 0) {
+    writer.writeInt32(
+      2,
+      f
+    );
+  }
+  f = message.getSavings();
+  if (f != null) {
+    writer.writeMessage(
+      3,
+      f,
+      proto.money_service.Money.serializeBinaryToWriter
+    );
+  }
+  f = message.getConvertedSavings();
+  if (f != null) {
+    writer.writeMessage(
+      4,
+      f,
+      proto.money_service.Money.serializeBinaryToWriter
+    );
+  }
+};
+
+
+/**
+ * optional string currency = 1;
+ * @return {string}
+ */
+proto.money_service.CurrencySavings.prototype.getCurrency = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 1, ""));
+};
+
+
+/**
+ * @param {string} value
+ * @return {!proto.money_service.CurrencySavings} returns this
+ */
+proto.money_service.CurrencySavings.prototype.setCurrency = function(value) {
+  return jspb.Message.setProto3StringField(this, 1, value);
+};
+
+
+/**
+ * optional int32 purchases = 2;
+ * @return {number}
+ */
+proto.money_service.CurrencySavings.prototype.getPurchases = function() {
+  return /** @type {number} */ (jspb.Message.getFieldWithDefault(this, 2, 0));
+};
+
+
+/**
+ * @param {number} value
+ * @return {!proto.money_service.CurrencySavings} returns this
+ */
+proto.money_service.CurrencySavings.prototype.setPurchases = function(value) {
+  return jspb.Message.setProto3IntField(this, 2, value);
+};
+
+
+/**
+ * optional Money savings = 3;
+ * @return {?proto.money_service.Money}
+ */
+proto.money_service.CurrencySavings.prototype.getSavings = function() {
+  return /** @type{?proto.money_service.Money} */ (
+    jspb.Message.getWrapperField(this, proto.money_service.Money, 3));
+};
+
+
+/**
+ * @param {?proto.money_service.Money|undefined} value
+ * @return {!proto.money_service.CurrencySavings} returns this
+*/
+proto.money_service.CurrencySavings.prototype.setSavings = function(value) {
+  return jspb.Message.setWrapperField(this, 3, value);
+};
+
+
+/**
+ * Clears the message field making it undefined.
+ * @return {!proto.money_service.CurrencySavings} returns this
+ */
+proto.money_service.CurrencySavings.prototype.clearSavings = function() {
+  return this.setSavings(undefined);
+};
+
+
+/**
+ * Returns whether this field is set.
+ * @return {boolean}
+ */
+proto.money_service.CurrencySavings.prototype.hasSavings = function() {
+  return jspb.Message.getField(this, 3) != null;
+};
+
+
+/**
+ * optional Money converted_savings = 4;
+ * @return {?proto.money_service.Money}
+ */
+proto.money_service.CurrencySavings.prototype.getConvertedSavings = function() {
+  return /** @type{?proto.money_service.Money} */ (
+    jspb.Message.getWrapperField(this, proto.money_service.Money, 4));
+};
+
+
+/**
+ * @param {?proto.money_service.Money|undefined} value
+ * @return {!proto.money_service.CurrencySavings} returns this
+*/
+proto.money_service.CurrencySavings.prototype.setConvertedSavings = function(value) {
+  return jspb.Message.setWrapperField(this, 4, value);
+};
+
+
+/**
+ * Clears the message field making it undefined.
+ * @return {!proto.money_service.CurrencySavings} returns this
+ */
+proto.money_service.CurrencySavings.prototype.clearConvertedSavings = function() {
+  return this.setConvertedSavings(undefined);
+};
+
+
+/**
+ * Returns whether this field is set.
+ * @return {boolean}
+ */
+proto.money_service.CurrencySavings.prototype.hasConvertedSavings = function() {
+  return jspb.Message.getField(this, 4) != null;
+};
+
+
 goog.object.extend(exports, proto.money_service);
 
 },{"google-protobuf":4}],3:[function(require,module,exports){
@@ -643,6 +1282,8 @@ window.proto = window.proto || {};
 window.proto.money_service = {
     GetSavingsRequest: proto.GetSavingsRequest,
     GetSavingsResponse: proto.GetSavingsResponse,
+    Money: proto.Money,
+    CurrencySavings: proto.CurrencySavings,
     MoneyServiceClient: service.MoneyServiceClient,
 };
 
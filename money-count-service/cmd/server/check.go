@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/database"
+)
+
+const checkAggregatesUsage = "использование: money-service [флаги] check-aggregates [-from 2025-05-01] [-to 2025-06-01] [-limit 20]"
+
+// runCheckAggregates сверяет почасовые агрегаты с расчётом по сырым событиям и печатает
+// расходящихся пользователей. Код выхода: 0 - всё сходится, 1 - есть расхождения или ошибка,
+// 2 - неверные аргументы; так команду удобно запускать по расписанию.
+func runCheckAggregates(ctx context.Context, store *database.ClickHouseStore, args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("check-aggregates", flag.ContinueOnError)
+	fs.SetOutput(out)
+	var period database.TimeRange
+	fs.Func("from", "начало периода: дата 2006-01-02 или RFC 3339, кратное часу", timeFlag(&period.From))
+	fs.Func("to", "конец периода не включительно", timeFlag(&period.To))
+	limit := fs.Int("limit", 20, "сколько расходящихся пользователей показать")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 || *limit <= 0 {
+		fmt.Fprintln(out, checkAggregatesUsage)
+		return 2
+	}
+
+	mismatches, total, err := store.CheckAggregates(ctx, period, *limit)
+	if err != nil {
+		slog.Error("aggregate consistency check failed", slog.Any("error", err))
+		return 1
+	}
+	if total == 0 {
+		slog.Info("hourly aggregates match raw events")
+		return 0
+	}
+
+	fmt.Fprintf(out, "%-12s %17s %17s %17s %27s\n", "user_id", "events raw/agg", "purchases raw/agg", "wallet raw/agg", "amount raw/agg")
+	for _, m := range mismatches {
+		fmt.Fprintf(out, "%-12d %8d/%-8d %8d/%-8d %8d/%-8d %13s/%-13s\n", m.UserID,
+			m.RawEvents, m.AggEvents, m.RawPurchases, m.AggPurchases,
+			m.RawWalletPurchases, m.AggWalletPurchases, m.RawAmount, m.AggAmount)
+	}
+	slog.Warn("hourly aggregates differ from raw events", slog.Uint64("users", total), slog.Int("shown", len(mismatches)))
+	return 1
+}
+
+// timeFlag разбирает дату или момент времени RFC 3339
+func timeFlag(dst *time.Time) func(string) error {
+	return func(v string) error {
+		for _, layout := range []string{time.DateOnly, time.RFC3339} {
+			if t, err := time.Parse(layout, v); err == nil {
+				*dst = t
+				return nil
+			}
+		}
+		return fmt.Errorf("ожидается дата 2006-01-02 или RFC 3339, получено %q", v)
+	}
+}
@@ -1,146 +1,419 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/Qwental/wb-money/internal/auth"
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/config"
+	"github.com/Qwental/wb-money/internal/cors"
 	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/internal/exchange"
 	"github.com/Qwental/wb-money/internal/handler"
+	"github.com/Qwental/wb-money/internal/health"
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/internal/metrics"
 	"github.com/Qwental/wb-money/internal/service"
+	"github.com/Qwental/wb-money/internal/tracing"
 	"github.com/Qwental/wb-money/pkg/proto"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
 
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 )
 
-// Значения по умолчанию
-const (
-	defaultGrpcPort = ":50051"
-	defaultWebPort  = ":8080"
-	defaultHost     = "0.0.0.0"
-)
+// newVerifier настраивает проверку JWT по HMAC-секрету или открытому RSA-ключу.
+// Если ничего не задано, возвращает nil - авторизация выключена.
+func newVerifier(cfg config.Auth) (*auth.Verifier, error) {
+	switch {
+	case cfg.JWTHMACSecret != "":
+		return auth.NewHMACVerifier([]byte(cfg.JWTHMACSecret))
+	case cfg.JWTRSAPublicKeyFile != "":
+		pem, err := os.ReadFile(cfg.JWTRSAPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewRSAVerifier(pem)
+	default:
+		return nil, nil
+	}
+}
 
-// getEnv возвращает значение переменной окружения или значение по умолчанию
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// clickhouseConfig переводит настройки сервиса в параметры подключения к ClickHouse
+func clickhouseConfig(cfg config.ClickHouse) (database.Config, error) {
+	dbCfg := database.Config{
+		Addrs:            cfg.Addrs(),
+		Database:         cfg.Database,
+		User:             cfg.User,
+		Password:         cfg.Password,
+		ConnOpenStrategy: cfg.ConnOpenStrategy,
+		DialTimeout:      cfg.DialTimeout,
+		MaxOpenConns:     cfg.MaxOpenConns,
+		MaxIdleConns:     cfg.MaxIdleConns,
+		ConnMaxLifetime:  cfg.ConnMaxLifetime,
+		Retry: database.Retry{
+			Attempts:       cfg.ConnectAttempts,
+			InitialBackoff: cfg.RetryBackoff,
+			MaxBackoff:     cfg.RetryMaxBackoff,
+		},
+	}
+	if cfg.TLS {
+		tlsCfg, err := database.TLSConfig(cfg.TLSCAFile, cfg.TLSSkipVerify)
+		if err != nil {
+			return database.Config{}, err
+		}
+		dbCfg.TLS = tlsCfg
 	}
-	return defaultValue
+	return dbCfg, nil
 }
 
-// buildDSN создает строку подключения к ClickHouse из переменных окружения
-func buildDSN() string {
-	host := getEnv("CLICKHOUSE_HOST", "localhost")
-	port := getEnv("CLICKHOUSE_PORT", "9000")
-	db := getEnv("CLICKHOUSE_DB", "default")
-	user := getEnv("CLICKHOUSE_USER", "default")
-	password := getEnv("CLICKHOUSE_PASSWORD", "")
+// storeOptions - настройки запросов к ClickHouse
+func storeOptions(cfg config.ClickHouse) database.StoreOptions {
+	return database.StoreOptions{
+		QueryTimeout: cfg.Timeout,
+		Retry: database.Retry{
+			Attempts:       cfg.QueryAttempts,
+			InitialBackoff: cfg.RetryBackoff,
+			MaxBackoff:     cfg.RetryMaxBackoff,
+		},
+		Source: database.Source(cfg.SavingsSource),
+	}
+}
 
-	if password != "" {
-		return fmt.Sprintf("clickhouse://%s:%s@%s:%s/%s", user, password, host, port, db)
+// newRatesProvider настраивает источник курсов валют. Файл проверяется сразу, чтобы ошибка
+// в нём не всплыла только на первом запросе; таблица exchange_rates читается при первом запросе.
+// Без источника возвращает nil - экономия считается только в валюте покупок.
+func newRatesProvider(cfg config.Rates, store *database.ClickHouseStore) (exchange.Provider, error) {
+	switch cfg.Source {
+	case "file":
+		if _, err := exchange.LoadFile(cfg.File); err != nil {
+			return nil, err
+		}
+		return exchange.NewCached(func(context.Context) ([]exchange.Rate, error) {
+			return exchange.ReadFile(cfg.File)
+		}, cfg.Refresh), nil
+	case "clickhouse":
+		return exchange.NewCached(store.ExchangeRates, cfg.Refresh), nil
+	default:
+		return nil, nil
 	}
-	return fmt.Sprintf("clickhouse://%s@%s:%s/%s", user, host, port, db)
+}
+
+// reloadRulesOnSignal перечитывает правила кэшбека при получении SIGHUP
+func reloadRulesOnSignal(rules *cashback.Engine) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := rules.Reload(); err != nil {
+			slog.Error("failed to reload cashback rules, keeping previous", slog.Any("error", err))
+			continue
+		}
+		slog.Info("reloaded cashback rules", slog.Int("rules", len(rules.Rules().Rules)))
+	}
+}
+
+// shutdown останавливает сервис: сначала снимает готовность (gRPC health и /readyz), затем
+// дожидается gRPC-Web запросов, потом gRPC и только после этого закрывает пул ClickHouse. HTTP останавливается раньше gRPC:
+// запросы gRPC-Web обслуживает тот же grpc.Server через ServeHTTP, а GracefulStop не умеет
+// завершать такие соединения. Не уложились в timeout - обрываем оставшиеся запросы.
+func shutdown(checker *health.Checker, grpcServer *grpc.Server, httpServer *http.Server, db *sqlx.DB, timeout time.Duration) {
+	checker.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		slog.Warn("gRPC-Web server did not stop gracefully", slog.Any("error", err))
+		_ = httpServer.Close()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("gRPC server did not stop in time, closing active streams", slog.Duration("timeout", timeout))
+		grpcServer.Stop()
+		<-stopped
+	}
+
+	if err := db.Close(); err != nil {
+		slog.Error("failed to close ClickHouse connection", slog.Any("error", err))
+	}
+}
+
+// fatal пишет ошибку запуска и завершает процесс
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
 func main() {
-	// Конфигурация из переменных окружения
-	grpcHost := getEnv("GRPC_HOST", defaultHost)
-	grpcPort := getEnv("GRPC_PORT", defaultGrpcPort)
-	webPort := getEnv("WEB_PORT", defaultWebPort)
+	// Конфигурация: значения по умолчанию, YAML-файл, переменные окружения и флаги
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 
-	grpcAddr := fmt.Sprintf("%s:%s", grpcHost, grpcPort)
-	webAddr := fmt.Sprintf("%s:%s", grpcHost, webPort)
+	// Логирование настраиваем сразу, чтобы все остальные сообщения шли в едином формате
+	if _, err := logging.Setup(os.Stderr, logging.Config{
+		Level:      cfg.Log.Level,
+		Format:     cfg.Log.Format,
+		UserIDMode: logging.UserIDMode(cfg.Log.UserIDMode),
+		UserIDSalt: cfg.Log.UserIDSalt,
+	}); err != nil {
+		log.Fatalf("Invalid logging config: %v", err)
+	}
+	for _, w := range cfg.Warnings {
+		slog.Warn("configuration warning", slog.String("warning", w))
+	}
 
-	dsn := buildDSN()
+	grpcAddr := net.JoinHostPort(cfg.Server.Host, cfg.Server.GRPCPort)
+	webAddr := net.JoinHostPort(cfg.Server.Host, cfg.Server.WebPort)
+	dsn := cfg.ClickHouse.DSN()
 
-	log.Printf("Starting Money Service...")
-	log.Printf("DSN: %s", dsn)
-	log.Printf("gRPC server will start on: %s", grpcAddr)
-	log.Printf("gRPC-Web server will start on: %s", webAddr)
+	slog.Info("starting Money Service",
+		slog.String("dsn", logging.RedactDSN(dsn)),
+		slog.String("grpc_addr", grpcAddr),
+		slog.String("web_addr", webAddr),
+		slog.Bool("tls", cfg.TLS.Enabled()),
+		slog.Bool("clickhouse_tls", cfg.ClickHouse.TLS))
 
-	// Подключение к ClickHouse
-	db, err := database.NewClickHouseDB(dsn)
+	// Сигнал остановки ловим с самого начала, чтобы он прерывал и ожидание ClickHouse при запуске
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Подключение к ClickHouse; пока он поднимается, повторяем с нарастающей паузой
+	dbCfg, err := clickhouseConfig(cfg.ClickHouse)
 	if err != nil {
-		log.Fatalf("Failed to connect to ClickHouse: %v", err)
+		fatal("invalid ClickHouse TLS config", slog.Any("error", err))
 	}
-	defer func(db *sqlx.DB) {
-		err := db.Close()
-		if err != nil {
-			log.Fatalf("Failed to close ClickHouseDB: %v", err)
+	db, err := database.Open(ctx, dbCfg, func(attempt int, err error) {
+		slog.Warn("ClickHouse is not available yet, retrying",
+			slog.Int("attempt", attempt), slog.Int("attempts", dbCfg.Retry.Attempts), slog.Any("error", err))
+	})
+	if err != nil {
+		fatal("failed to connect to ClickHouse", slog.Any("error", err))
+	}
+
+	slog.Info("connected to ClickHouse", slog.Any("replicas", dbCfg.Addrs))
+
+	// Подкоманды выполняются вместо запуска серверов
+	if len(cfg.Args) > 0 {
+		code := 0
+		switch cfg.Args[0] {
+		case "migrate":
+			if err := runMigrate(ctx, db, cfg.Args[1:], os.Stdout); err != nil {
+				slog.Error("migration failed", slog.Any("error", err))
+				code = 1
+			}
+		case "check-aggregates":
+			store := database.NewClickHouseStore(db, storeOptions(cfg.ClickHouse))
+			code = runCheckAggregates(ctx, store, cfg.Args[1:], os.Stdout)
+		default:
+			slog.Error("unknown command", slog.String("command", cfg.Args[0]),
+				slog.String("usage", migrateUsage+"; "+checkAggregatesUsage))
+			code = 2
 		}
-	}(db)
+		_ = db.Close()
+		os.Exit(code)
+	}
+
+	// Схема по требованию приводится к последней версии ещё до приёма запросов
+	if cfg.ClickHouse.AutoMigrate {
+		if err := runMigrate(ctx, db, []string{"up"}, os.Stdout); err != nil {
+			fatal("failed to migrate ClickHouse schema", slog.Any("error", err))
+		}
+	}
+
+	// Трассировка: W3C trace context всегда, экспорт по OTLP - если задан OTEL_EXPORTER_OTLP_ENDPOINT
+	shutdownTracing, tracingEnabled, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal("failed to set up tracing", slog.Any("error", err))
+	}
+	if tracingEnabled {
+		slog.Info("OpenTelemetry tracing enabled")
+	}
 
-	log.Printf("Successfully connected to ClickHouse")
+	// Правила кэшбека; перечитываются по SIGHUP без перезапуска сервиса
+	rules, err := cashback.NewEngineWithDefault(cfg.Cashback.RulesFile,
+		cashback.DefaultRuleSetWithPercent(cfg.Cashback.DefaultPercent))
+	if err != nil {
+		fatal("failed to load cashback rules", slog.Any("error", err))
+	}
+	slog.Info("loaded cashback rules", slog.Int("rules", len(rules.Rules().Rules)))
+	go reloadRulesOnSignal(rules)
 
 	// Инициализация сервисов
-	svc := service.NewMoneyService(db)
+	store := database.NewClickHouseStore(db, storeOptions(cfg.ClickHouse))
+	rates, err := newRatesProvider(cfg.Rates, store)
+	if err != nil {
+		fatal("failed to load exchange rates", slog.Any("error", err))
+	}
+	slog.Info("exchange rates source", slog.String("source", cfg.Rates.Source))
+	svc := service.NewMoneyService(store, rules, exchange.NewConverter(rates))
+	slog.Info("savings source", slog.String("source", cfg.ClickHouse.SavingsSource))
 	h := handler.NewMoneyHandler(svc)
 
+	// gRPC-коды ошибок вместо статуса только в теле ответа; клиент может переопределить заголовком
+	statusCodes := cfg.Server.StatusCodes
+
+	methodTimeouts, err := cfg.Server.MethodTimeouts()
+	if err != nil {
+		fatal("invalid grpc method timeouts", slog.Any("error", err))
+	}
+	timeouts, err := handler.NewTimeouts(cfg.Server.GRPCTimeout, methodTimeouts)
+	if err != nil {
+		fatal("invalid grpc method timeouts", slog.Any("error", err))
+	}
+
+	// Логгер запроса с request_id - первым, затем дедлайн вызова (GRPC_TIMEOUT, GRPC_METHOD_TIMEOUTS) и перевод статусов
+	// в gRPC-коды, чтобы увидеть и отказ авторизации; метрики - за ним, чтобы видеть статус в теле ответа в любом режиме
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		handler.RequestIDUnaryInterceptor(),
+		handler.DeadlineUnaryInterceptor(timeouts),
+		handler.StatusCodesUnaryInterceptor(statusCodes),
+		handler.MetricsUnaryInterceptor(),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		handler.RequestIDStreamInterceptor(),
+		handler.DeadlineStreamInterceptor(timeouts),
+		handler.StatusCodesStreamInterceptor(statusCodes),
+		handler.MetricsStreamInterceptor(),
+	}
+
+	// JWT-авторизация; действует и для gRPC-Web, так как обёртка вызывает тот же gRPC сервер
+	verifier, err := newVerifier(cfg.Auth)
+	if err != nil {
+		fatal("failed to configure JWT auth", slog.Any("error", err))
+	}
+	if verifier != nil {
+		unaryInterceptors = append(unaryInterceptors, handler.AuthUnaryInterceptor(verifier))
+		streamInterceptors = append(streamInterceptors, handler.AuthStreamInterceptor(verifier))
+		slog.Info("JWT auth enabled")
+	} else {
+		slog.Warn("JWT auth disabled, any caller can read any user's savings")
+	}
+
 	// Создание gRPC сервера
-	grpcServer := grpc.NewServer()
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	if cfg.TLS.Enabled() {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			fatal("failed to load TLS certificate", slog.Any("error", err))
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
 	proto.RegisterMoneyServiceServer(grpcServer, h)
 	reflection.Register(grpcServer)
 
+	// grpc.health.v1 и /readyz отражают доступность ClickHouse и наличие таблицы событий
+	checker := health.NewChecker(health.ClickHouseProbe(db), cfg.Server.HealthCheckInterval, proto.MoneyService_ServiceDesc.ServiceName)
+	healthpb.RegisterHealthServer(grpcServer, checker.Server())
+
+	// Разрешённые origin для браузерных запросов; по умолчанию - фронтенд из docker-compose
+	allowList, err := cors.ParseAllowList(cfg.CORS.AllowedOrigins)
+	if err != nil {
+		fatal("invalid CORS_ALLOWED_ORIGINS", slog.Any("error", err))
+	}
+	metrics.RegisterCORSRejected(allowList.Rejected)
+
 	// gRPC-Web обёртка
 	wrappedGrpc := grpcweb.WrapServer(grpcServer,
 		grpcweb.WithCorsForRegisteredEndpointsOnly(false),
-		grpcweb.WithOriginFunc(func(origin string) bool {
-			// В продакшене здесь должна быть более строгая проверка
-			return true
-		}),
+		grpcweb.WithOriginFunc(allowList.Allowed),
 	)
 
-	// HTTP сервер для gRPC-Web
+	// HTTP эндпоинты проверок и метрик; /health оставлен для совместимости и равен /readyz
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/livez", checker.LivezHandler())
+	mux.Handle("/readyz", checker.ReadyzHandler())
+	mux.Handle("/health", checker.ReadyzHandler())
+
+	// HTTP сервер для gRPC-Web; CORS проверяется до маршрутизации
 	httpServer := &http.Server{
 		Addr: webAddr,
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// CORS заголовки для preflight запросов
-			if r.Method == "OPTIONS" {
-				w.Header().Set("Access-Control-Allow-Origin", "*")
-				w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-				w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Grpc-Web, X-User-Agent")
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
+		Handler: tracing.HTTPHandler(allowList.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if wrappedGrpc.IsGrpcWebRequest(r) || wrappedGrpc.IsAcceptableGrpcCorsRequest(r) {
 				wrappedGrpc.ServeHTTP(w, r)
-			} else {
-				// Для обычных HTTP запросов можно добавить health check
-				if r.URL.Path == "/health" {
-					w.WriteHeader(http.StatusOK)
-					_, err := w.Write([]byte("OK"))
-					if err != nil {
-						return
-
-					}
-					return
-				}
-				http.NotFound(w, r)
+				return
 			}
-		}),
+			mux.ServeHTTP(w, r)
+		})), "/metrics", "/livez", "/readyz", "/health"),
+	}
+
+	// Порты занимаем до запуска, чтобы ошибка была видна сразу
+	grpcLis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		fatal("failed to listen", slog.String("addr", grpcAddr), slog.Any("error", err))
+	}
+	webLis, err := net.Listen("tcp", webAddr)
+	if err != nil {
+		fatal("failed to listen", slog.String("addr", webAddr), slog.Any("error", err))
 	}
 
-	// Запускаем обычный gRPC сервер в отдельной горутине
+	// Серверы работают в отдельных горутинах; падение любого из них останавливает сервис
+	serveErr := make(chan error, 2)
 	go func() {
-		lis, err := net.Listen("tcp", grpcAddr)
-		if err != nil {
-			log.Fatalf("Failed to listen on %s: %v", grpcAddr, err)
+		slog.Info("gRPC server started", slog.String("addr", grpcAddr))
+		if err := grpcServer.Serve(grpcLis); err != nil {
+			serveErr <- fmt.Errorf("gRPC server: %w", err)
 		}
-		log.Printf("gRPC server started on %s", grpcAddr)
-		if err := grpcServer.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve gRPC: %v", err)
+	}()
+	go func() {
+		slog.Info("gRPC-Web server started", slog.String("addr", webAddr))
+		serve := func() error { return httpServer.Serve(webLis) }
+		if cfg.TLS.Enabled() {
+			serve = func() error { return httpServer.ServeTLS(webLis, cfg.TLS.CertFile, cfg.TLS.KeyFile) }
+		}
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("gRPC-Web server: %w", err)
 		}
 	}()
 
-	// Запускаем gRPC-Web сервер
-	log.Printf("gRPC-Web server started on %s", webAddr)
-	if err := httpServer.ListenAndServe(); err != nil {
-		log.Fatalf("HTTP server failed: %v", err)
+	go checker.Run(ctx)
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("received shutdown signal, stopping", slog.Duration("timeout", cfg.Server.ShutdownTimeout))
+	case err := <-serveErr:
+		slog.Error("server failed, stopping", slog.Any("error", err))
+		exitCode = 1
+	}
+	stop()
+
+	shutdown(checker, grpcServer, httpServer, db, cfg.Server.ShutdownTimeout)
+
+	// Досылаем накопленные спаны
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("failed to flush traces", slog.Any("error", err))
 	}
+	cancel()
+	slog.Info("Money Service stopped")
+	os.Exit(exitCode)
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/jmoiron/sqlx"
+)
+
+const migrateUsage = "использование: money-service [флаги] migrate [up | down [N] | status]"
+
+// runMigrate выполняет подкоманду migrate: up (по умолчанию) применяет все новые миграции,
+// down откатывает N последних (по умолчанию одну), status печатает состояние схемы
+func runMigrate(ctx context.Context, db *sqlx.DB, args []string, out io.Writer) error {
+	migrations, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	migrator := database.NewMigrator(db, migrations)
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch {
+	case cmd == "up" && len(args) == 0:
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			slog.Info("applied migration", slog.Uint64("version", uint64(m.Version)), slog.String("name", m.Name))
+		}
+		if err == nil && len(applied) == 0 {
+			slog.Info("schema is up to date")
+		}
+		return err
+
+	case cmd == "down" && len(args) <= 1:
+		steps := 1
+		if len(args) == 1 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps <= 0 {
+				return fmt.Errorf("число миграций для отката должно быть положительным, получено %q", args[0])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			slog.Info("reverted migration", slog.Uint64("version", uint64(m.Version)), slog.String("name", m.Name))
+		}
+		return err
+
+	case cmd == "status" && len(args) == 0:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(out, "%04d  %-8s %s\n", st.Version, state, st.Name)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%s", migrateUsage)
+	}
+}
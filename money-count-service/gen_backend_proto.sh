@@ -74,6 +74,6 @@ fi
 
 # Дополнительная информация
 echo -e "${BLUE} Совет: Для компиляции сервера используйте:${NC}"
-echo -e "   ${YELLOW}go run cmd/server/main.go${NC}"
+echo -e "   ${YELLOW}go run ./cmd/server${NC}"
 echo -e "${BLUE} Для сборки бинарника:${NC}"
-echo -e "   ${YELLOW}go build -o bin/money-service cmd/server/main.go${NC}"
\ No newline at end of file
+echo -e "   ${YELLOW}go build -o bin/money-service ./cmd/server${NC}"
\ No newline at end of file
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RoleService - роль внутренних сервисов (CRM и т.п.), которым доступны данные любых пользователей
+const RoleService = "service"
+
+var (
+	ErrNoToken      = errors.New("не передан токен")
+	ErrInvalidToken = errors.New("некорректный токен")
+)
+
+// Identity - кто делает запрос: пользователь (UserID) или внутренний сервис
+type Identity struct {
+	UserID  uint64
+	Service bool
+}
+
+// CanAccess проверяет, можно ли читать данные пользователя userID
+func (id Identity) CanAccess(userID uint64) bool {
+	return id.Service || (id.UserID != 0 && id.UserID == userID)
+}
+
+// claims - полезная нагрузка токена: sub - ID пользователя, role - роль вызывающего
+type claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role,omitempty"`
+}
+
+// Verifier проверяет JWT, подписанные общим HMAC-секретом или закрытым RSA-ключом
+type Verifier struct {
+	key    any
+	parser *jwt.Parser
+}
+
+// NewHMACVerifier - проверка токенов HS256/HS384/HS512
+func NewHMACVerifier(secret []byte) (*Verifier, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("пустой HMAC-секрет")
+	}
+	return &Verifier{
+		key:    secret,
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}), jwt.WithExpirationRequired()),
+	}, nil
+}
+
+// NewRSAVerifier - проверка токенов RS256/RS384/RS512 по открытому ключу в PEM
+func NewRSAVerifier(publicKeyPEM []byte) (*Verifier, error) {
+	key, err := jwt.ParseRSAPublicKeyFromPEM(publicKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("разбор открытого RSA-ключа: %w", err)
+	}
+	return &Verifier{
+		key:    key,
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}), jwt.WithExpirationRequired()),
+	}, nil
+}
+
+// Verify проверяет подпись и срок действия токена и возвращает личность вызывающего
+func (v *Verifier) Verify(token string) (Identity, error) {
+	if token == "" {
+		return Identity{}, ErrNoToken
+	}
+
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.keyFunc); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if c.Role == RoleService {
+		return Identity{Service: true}, nil
+	}
+	userID, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil || userID == 0 {
+		return Identity{}, fmt.Errorf("%w: sub должен быть ID пользователя", ErrInvalidToken)
+	}
+	return Identity{UserID: userID}, nil
+}
+
+// keyFunc отдаёт ключ верификатора; алгоритм уже ограничен WithValidMethods,
+// поэтому подменить RSA на HMAC с открытым ключом в качестве секрета не получится
+func (v *Verifier) keyFunc(*jwt.Token) (any, error) {
+	return v.key, nil
+}
+
+// BearerToken достаёт токен из значения заголовка authorization
+func BearerToken(header string) string {
+	const prefix = "bearer "
+	if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+		return strings.TrimSpace(header[len(prefix):])
+	}
+	return ""
+}
+
+type identityKey struct{}
+
+// WithIdentity сохраняет личность вызывающего в контексте запроса
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext возвращает личность вызывающего, если запрос прошёл аутентификацию
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
@@ -0,0 +1,86 @@
+package cashback
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+var errUnknownFormat = errors.New("поддерживаются только файлы .yaml, .yml и .json")
+
+// Engine хранит текущий набор правил и позволяет перечитать его без перезапуска сервиса
+type Engine struct {
+	path  string
+	rules atomic.Pointer[RuleSet]
+}
+
+// NewEngine загружает правила из YAML/JSON файла. Пустой путь означает правила по умолчанию.
+func NewEngine(path string) (*Engine, error) {
+	return NewEngineWithDefault(path, DefaultRuleSet())
+}
+
+// NewEngineWithDefault - то же, но без файла действуют переданные правила
+func NewEngineWithDefault(path string, defaults *RuleSet) (*Engine, error) {
+	e := &Engine{path: path}
+	if path == "" {
+		if err := defaults.Validate(); err != nil {
+			return nil, fmt.Errorf("правила кэшбека по умолчанию: %w", err)
+		}
+		e.rules.Store(defaults)
+		return e, nil
+	}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Rules возвращает текущий набор правил. Набор неизменяем, его можно использовать
+// до конца запроса, даже если правила перечитаются параллельно.
+func (e *Engine) Rules() *RuleSet {
+	return e.rules.Load()
+}
+
+// Reload перечитывает файл с правилами. При ошибке продолжают действовать прежние правила.
+func (e *Engine) Reload() error {
+	if e.path == "" {
+		return nil
+	}
+	rs, err := LoadFile(e.path)
+	if err != nil {
+		return err
+	}
+	e.rules.Store(rs)
+	return nil
+}
+
+// LoadFile читает и проверяет набор правил; формат определяется по расширению файла
+func LoadFile(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("чтение правил кэшбека: %w", err)
+	}
+
+	var rs RuleSet
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &rs)
+	case ".json":
+		err = json.Unmarshal(data, &rs)
+	default:
+		err = errUnknownFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("разбор правил кэшбека %s: %w", path, err)
+	}
+
+	if err := rs.Validate(); err != nil {
+		return nil, fmt.Errorf("правила кэшбека %s: %w", path, err)
+	}
+	return &rs, nil
+}
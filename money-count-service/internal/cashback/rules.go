@@ -0,0 +1,236 @@
+package cashback
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/money"
+)
+
+// DefaultRuleID - правило, которое действует, если файл с правилами не задан
+const DefaultRuleID = "default"
+
+// Rule описывает кэшбек, который покупка получила бы при оплате WB-кошельком.
+// Пустое условие не ограничивает покупку; все заданные условия должны выполняться одновременно.
+type Rule struct {
+	ID             string     `json:"id" yaml:"id"`
+	Percent        float64    `json:"percent" yaml:"percent"`                                     // 3 означает 3%
+	PaymentMethods []string   `json:"payment_methods,omitempty" yaml:"payment_methods,omitempty"` // исходный способ оплаты покупки
+	Categories     []string   `json:"categories,omitempty" yaml:"categories,omitempty"`
+	MinAmount      float64    `json:"min_amount,omitempty" yaml:"min_amount,omitempty"` // порог для рублёвых покупок, с точностью до копейки
+	ValidFrom      *time.Time `json:"valid_from,omitempty" yaml:"valid_from,omitempty"` // начало акции включительно
+	ValidTo        *time.Time `json:"valid_to,omitempty" yaml:"valid_to,omitempty"`     // конец акции не включительно
+
+	// MinAmountByCurrency - порог для покупок в других валютах, в их единицах. Если у правила есть
+	// порог, покупка в валюте без порога под правило не подходит: суммы разных валют не сравниваются.
+	MinAmountByCurrency map[string]float64 `json:"min_amount_by_currency,omitempty" yaml:"min_amount_by_currency,omitempty"`
+}
+
+// Purchase - покупка, к которой применяются правила
+type Purchase struct {
+	Timestamp     time.Time
+	Amount        money.Amount
+	Currency      string // валюта Amount; пустая - рубли
+	PaymentMethod string
+	Category      string
+}
+
+// RuleSet - упорядоченный список правил (к покупке применяется первое подошедшее)
+// и уровни кэшбека по возрастанию
+type RuleSet struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+	Tiers []Tier `json:"tiers,omitempty" yaml:"tiers,omitempty"`
+}
+
+// DefaultPercent - процент кэшбека с любой покупки, если правила не заданы
+const DefaultPercent = 3.0
+
+// DefaultRuleSet - 3% с любой покупки без уровней
+func DefaultRuleSet() *RuleSet {
+	return DefaultRuleSetWithPercent(DefaultPercent)
+}
+
+// DefaultRuleSetWithPercent - одно правило на все покупки с заданным процентом. Уровней нет,
+// как и в файле правил без секции tiers: без файла кэшбек ровно percent.
+func DefaultRuleSetWithPercent(percent float64) *RuleSet {
+	return &RuleSet{Rules: []Rule{{ID: DefaultRuleID, Percent: percent}}}
+}
+
+// Matches проверяет, подходит ли покупка под правило
+func (r Rule) Matches(p Purchase) bool {
+	if len(r.PaymentMethods) > 0 && !slices.Contains(r.PaymentMethods, p.PaymentMethod) {
+		return false
+	}
+	if len(r.Categories) > 0 && !slices.Contains(r.Categories, p.Category) {
+		return false
+	}
+	if min, ok := r.minAmount(p.Currency); !ok || p.Amount < min {
+		return false
+	}
+	if r.ValidFrom != nil && p.Timestamp.Before(*r.ValidFrom) {
+		return false
+	}
+	if r.ValidTo != nil && !p.Timestamp.Before(*r.ValidTo) {
+		return false
+	}
+	return true
+}
+
+// hasMinAmount сообщает, что правило ограничивает сумму покупки хотя бы в одной валюте
+func (r Rule) hasMinAmount() bool {
+	return r.MinAmount > 0 || len(r.MinAmountByCurrency) > 0
+}
+
+// minAmount возвращает порог суммы для покупки в валюте currency; false - покупка в этой валюте не подходит
+func (r Rule) minAmount(currency string) (money.Amount, bool) {
+	if !r.hasMinAmount() {
+		return 0, true
+	}
+	if currency == "" || currency == models.DefaultCurrency {
+		return money.FromFloat(r.MinAmount), true
+	}
+	min, ok := r.MinAmountByCurrency[currency]
+	return money.FromFloat(min), ok
+}
+
+// Match возвращает первое правило, подходящее под покупку
+func (rs *RuleSet) Match(p Purchase) (Rule, bool) {
+	for _, r := range rs.Rules {
+		if r.Matches(p) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Validate проверяет правила после загрузки из файла
+func (rs *RuleSet) Validate() error {
+	seen := make(map[string]bool, len(rs.Rules))
+	for i, r := range rs.Rules {
+		if r.ID == "" {
+			return fmt.Errorf("правило #%d: не задан id", i+1)
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("правило %q: id повторяется", r.ID)
+		}
+		seen[r.ID] = true
+
+		if r.Percent < 0 || r.Percent > 100 {
+			return fmt.Errorf("правило %q: процент должен быть от 0 до 100", r.ID)
+		}
+		if r.MinAmount < 0 {
+			return fmt.Errorf("правило %q: min_amount не может быть отрицательным", r.ID)
+		}
+		for currency, min := range r.MinAmountByCurrency {
+			if !models.ValidCurrency(currency) || currency == models.DefaultCurrency {
+				return fmt.Errorf("правило %q: min_amount_by_currency: некорректная валюта %q (порог в рублях - min_amount)", r.ID, currency)
+			}
+			if min < 0 {
+				return fmt.Errorf("правило %q: min_amount_by_currency[%s] не может быть отрицательным", r.ID, currency)
+			}
+		}
+		if r.ValidFrom != nil && r.ValidTo != nil && !r.ValidFrom.Before(*r.ValidTo) {
+			return fmt.Errorf("правило %q: valid_from должен быть раньше valid_to", r.ID)
+		}
+	}
+	return rs.validateTiers()
+}
+
+// Aggregatable сообщает, что правила дают тот же результат на агрегатах покупок с шагом step
+// (по способу оплаты, категории и интервалу времени): условия не смотрят на сумму отдельной
+// покупки, а границы акций кратны step
+func (rs *RuleSet) Aggregatable(step time.Duration) bool {
+	for _, r := range rs.Rules {
+		if r.hasMinAmount() {
+			return false
+		}
+		if r.ValidFrom != nil && !r.ValidFrom.Truncate(step).Equal(*r.ValidFrom) ||
+			r.ValidTo != nil && !r.ValidTo.Truncate(step).Equal(*r.ValidTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// Expr - выражение ClickHouse вместе с аргументами для его плейсхолдеров
+type Expr struct {
+	SQL  string
+	Args []any
+}
+
+// RuleIDExpr компилирует правила в выражение ClickHouse, возвращающее id первого подошедшего правила
+// или пустую строку. Выражение ссылается на колонки amount (в копейках), currency, payment_method, category
+// и timestamp.
+func (rs *RuleSet) RuleIDExpr() Expr {
+	return rs.multiIf(func(r Rule) (string, []any) { return "?", []any{r.ID} }, "''")
+}
+
+func (rs *RuleSet) multiIf(result func(Rule) (string, []any), otherwise string) Expr {
+	if len(rs.Rules) == 0 {
+		return Expr{SQL: otherwise}
+	}
+
+	var (
+		parts []string
+		args  []any
+	)
+	for _, r := range rs.Rules {
+		cond, condArgs := r.condition()
+		res, resArgs := result(r)
+		parts = append(parts, cond, res)
+		args = append(append(args, condArgs...), resArgs...)
+	}
+	parts = append(parts, otherwise)
+
+	return Expr{SQL: "multiIf(" + strings.Join(parts, ", ") + ")", Args: args}
+}
+
+// condition - SQL-аналог Matches
+func (r Rule) condition() (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	if len(r.PaymentMethods) > 0 {
+		conds = append(conds, "payment_method IN ("+placeholders(len(r.PaymentMethods))+")")
+		for _, m := range r.PaymentMethods {
+			args = append(args, m)
+		}
+	}
+	if len(r.Categories) > 0 {
+		conds = append(conds, "category IN ("+placeholders(len(r.Categories))+")")
+		for _, c := range r.Categories {
+			args = append(args, c)
+		}
+	}
+	if r.hasMinAmount() {
+		// Порог своей валюты; валюта без порога не проходит, как в minAmount
+		branches := []string{"currency = ?", "amount >= ?"}
+		args = append(args, models.DefaultCurrency, int64(money.FromFloat(r.MinAmount)))
+		for _, currency := range slices.Sorted(maps.Keys(r.MinAmountByCurrency)) {
+			branches = append(branches, "currency = ?", "amount >= ?")
+			args = append(args, currency, int64(money.FromFloat(r.MinAmountByCurrency[currency])))
+		}
+		conds = append(conds, "multiIf("+strings.Join(branches, ", ")+", 0)")
+	}
+	if r.ValidFrom != nil {
+		conds = append(conds, "timestamp >= ?")
+		args = append(args, *r.ValidFrom)
+	}
+	if r.ValidTo != nil {
+		conds = append(conds, "timestamp < ?")
+		args = append(args, *r.ValidTo)
+	}
+	if len(conds) == 0 {
+		return "1", nil
+	}
+	return "(" + strings.Join(conds, " AND ") + ")", args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
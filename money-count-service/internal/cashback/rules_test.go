@@ -0,0 +1,160 @@
+package cashback_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+)
+
+func TestRuleSetMatch(t *testing.T) {
+	rs, err := cashback.LoadFile("../../cashback_rules.example.yaml")
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+
+	summer := time.Date(2025, time.July, 10, 12, 0, 0, 0, time.UTC)
+	winter := time.Date(2025, time.December, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		purchase cashback.Purchase
+		want     string
+	}{
+		{"promo category in window", cashback.Purchase{Timestamp: summer, Amount: 10000, PaymentMethod: "card", Category: "electronics"}, "summer-electronics"},
+		{"promo category after window", cashback.Purchase{Timestamp: winter, Amount: 10000, PaymentMethod: "card", Category: "electronics"}, "default"},
+		{"min amount reached", cashback.Purchase{Timestamp: winter, Amount: 500000, PaymentMethod: "cash"}, "big-order"},
+		{"payment method", cashback.Purchase{Timestamp: winter, Amount: 499999, PaymentMethod: "cash"}, "cash"},
+		{"min amount in kzt reached", cashback.Purchase{Timestamp: winter, Amount: 2500000, Currency: "KZT", PaymentMethod: "cash"}, "big-order"},
+		{"min amount in kzt missed", cashback.Purchase{Timestamp: winter, Amount: 2499999, Currency: "KZT", PaymentMethod: "cash"}, "cash"},
+		{"rub threshold not applied to kzt", cashback.Purchase{Timestamp: winter, Amount: 500000, Currency: "KZT", PaymentMethod: "cash"}, "cash"},
+		{"currency without threshold", cashback.Purchase{Timestamp: winter, Amount: 10000000, Currency: "USD", PaymentMethod: "cash"}, "cash"},
+		{"fallback", cashback.Purchase{Timestamp: winter, Amount: 10000, PaymentMethod: "card"}, "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rs.Match(tt.purchase)
+			if !ok || got.ID != tt.want {
+				t.Errorf("Match = %q (%v), want %q", got.ID, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSetSQL(t *testing.T) {
+	from := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	rs := &cashback.RuleSet{Rules: []cashback.Rule{
+		{ID: "promo", Percent: 5, PaymentMethods: []string{"card", "cash"}, MinAmount: 1000, MinAmountByCurrency: map[string]float64{"KZT": 5000}, ValidFrom: &from},
+		{ID: "default", Percent: 3},
+	}}
+
+	id := rs.RuleIDExpr()
+	wantSQL := "multiIf((payment_method IN (?, ?) AND multiIf(currency = ?, amount >= ?, currency = ?, amount >= ?, 0) AND timestamp >= ?), ?, 1, ?, '')"
+	if id.SQL != wantSQL {
+		t.Errorf("RuleIDExpr SQL = %s, want %s", id.SQL, wantSQL)
+	}
+	wantArgs := []any{"card", "cash", "RUB", int64(100000), "KZT", int64(500000), from, "promo", "default"}
+	if !reflect.DeepEqual(id.Args, wantArgs) {
+		t.Errorf("RuleIDExpr args = %v, want %v", id.Args, wantArgs)
+	}
+
+	if empty := (&cashback.RuleSet{}).RuleIDExpr(); empty.SQL != "''" {
+		t.Errorf("empty RuleIDExpr = %s, want ''", empty.SQL)
+	}
+}
+
+func TestRuleSetAggregatable(t *testing.T) {
+	midnight := time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	halfPast := midnight.Add(30 * time.Minute)
+
+	tests := []struct {
+		name string
+		rule cashback.Rule
+		want bool
+	}{
+		{"default", cashback.Rule{ID: "default", Percent: 3}, true},
+		{"method and category", cashback.Rule{ID: "r", PaymentMethods: []string{"card"}, Categories: []string{"books"}}, true},
+		{"hour aligned window", cashback.Rule{ID: "r", ValidFrom: &midnight, ValidTo: &midnight}, true},
+		{"min amount", cashback.Rule{ID: "r", MinAmount: 1000}, false},
+		{"min amount in currency", cashback.Rule{ID: "r", MinAmountByCurrency: map[string]float64{"KZT": 5000}}, false},
+		{"window inside hour", cashback.Rule{ID: "r", ValidTo: &halfPast}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := &cashback.RuleSet{Rules: []cashback.Rule{tt.rule}}
+			if got := rs.Aggregatable(time.Hour); got != tt.want {
+				t.Errorf("Aggregatable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineReloadKeepsRulesOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`{"rules": [{"id": "flat", "percent": 4}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := cashback.NewEngine(path)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"rules": [{"id": "flat", "percent": 400}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Reload(); err == nil {
+		t.Fatal("Reload accepted percent > 100")
+	}
+	if rules := e.Rules().Rules; len(rules) != 1 || rules[0].Percent != 4 {
+		t.Errorf("rules after failed reload = %+v", e.Rules())
+	}
+}
+
+func TestRuleSetTierFor(t *testing.T) {
+	rs := &cashback.RuleSet{Tiers: []cashback.Tier{
+		{Level: 1, MinWalletOrders: 0, BonusPercent: 0},
+		{Level: 2, MinWalletOrders: 10, BonusPercent: 1},
+		{Level: 3, MinWalletOrders: 20, BonusPercent: 2},
+	}}
+
+	tests := []struct {
+		walletOrders int
+		wantLevel    int
+		wantNext     int // 0 - следующего уровня нет
+	}{
+		{0, 1, 2},
+		{9, 1, 2},
+		{10, 2, 3},
+		{25, 3, 0},
+	}
+
+	for _, tt := range tests {
+		current, next := rs.TierFor(tt.walletOrders)
+		if current.Level != tt.wantLevel {
+			t.Errorf("TierFor(%d) level = %d, want %d", tt.walletOrders, current.Level, tt.wantLevel)
+		}
+		if (next == nil && tt.wantNext != 0) || (next != nil && next.Level != tt.wantNext) {
+			t.Errorf("TierFor(%d) next = %+v, want level %d", tt.walletOrders, next, tt.wantNext)
+		}
+	}
+
+	if current, next := (&cashback.RuleSet{}).TierFor(100); current.Level != 0 || next != nil {
+		t.Errorf("TierFor without tiers = %+v, %+v", current, next)
+	}
+}
+
+func TestDefaultRuleSetWithPercent(t *testing.T) {
+	rs := cashback.DefaultRuleSetWithPercent(4.5)
+	if len(rs.Rules) != 1 || rs.Rules[0].ID != cashback.DefaultRuleID || rs.Rules[0].Percent != 4.5 {
+		t.Errorf("rules = %+v", rs.Rules)
+	}
+	// без файла правил надбавок за уровни нет, как в файле без секции tiers
+	if len(rs.Tiers) != 0 {
+		t.Errorf("tiers = %+v, want none", rs.Tiers)
+	}
+}
@@ -0,0 +1,42 @@
+package cashback
+
+import "fmt"
+
+// Tier - уровень геймификации: чем больше покупок оплачено WB-кошельком,
+// тем выше надбавка к проценту кэшбека по правилам
+type Tier struct {
+	Level           int     `json:"level" yaml:"level"`
+	MinWalletOrders int     `json:"min_wallet_orders" yaml:"min_wallet_orders"`
+	BonusPercent    float64 `json:"bonus_percent" yaml:"bonus_percent"` // 1 означает +1% к правилу
+}
+
+// TierFor возвращает текущий уровень пользователя и следующий, если он есть.
+// Если уровни не настроены или покупок не хватает даже на первый, текущий уровень нулевой.
+func (rs *RuleSet) TierFor(walletOrders int) (current Tier, next *Tier) {
+	for i, t := range rs.Tiers {
+		if walletOrders < t.MinWalletOrders {
+			return current, &rs.Tiers[i]
+		}
+		current = t
+	}
+	return current, nil
+}
+
+func (rs *RuleSet) validateTiers() error {
+	for i, t := range rs.Tiers {
+		if t.MinWalletOrders < 0 {
+			return fmt.Errorf("уровень %d: min_wallet_orders не может быть отрицательным", t.Level)
+		}
+		if t.BonusPercent < 0 || t.BonusPercent > 100 {
+			return fmt.Errorf("уровень %d: bonus_percent должен быть от 0 до 100", t.Level)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := rs.Tiers[i-1]
+		if t.Level <= prev.Level || t.MinWalletOrders <= prev.MinWalletOrders {
+			return fmt.Errorf("уровень %d: уровни должны идти по возрастанию level и min_wallet_orders", t.Level)
+		}
+	}
+	return nil
+}
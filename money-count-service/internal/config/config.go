@@ -0,0 +1,490 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config - все настройки сервиса. Источники по возрастанию приоритета: значения по умолчанию,
+// YAML-файл (-config или CONFIG_FILE), переменные окружения, флаги командной строки.
+type Config struct {
+	Server     Server     `yaml:"server"`
+	TLS        TLS        `yaml:"tls"`
+	ClickHouse ClickHouse `yaml:"clickhouse"`
+	Cashback   Cashback   `yaml:"cashback"`
+	Rates      Rates      `yaml:"rates"`
+	CORS       CORS       `yaml:"cors"`
+	Auth       Auth       `yaml:"auth"`
+	Log        Log        `yaml:"log"`
+	Debug      bool       `yaml:"debug"`
+
+	// Args - аргументы после флагов, например подкоманда migrate
+	Args []string `yaml:"-"`
+	// Warnings - допустимые, но подозрительные сочетания настроек; main пишет их в лог
+	Warnings []string `yaml:"-"`
+}
+
+// Server - адреса gRPC и gRPC-Web серверов и их таймауты
+type Server struct {
+	Host                string        `yaml:"host"`
+	GRPCPort            string        `yaml:"grpc_port"`
+	WebPort             string        `yaml:"web_port"`
+	GRPCTimeout         time.Duration `yaml:"grpc_timeout"`
+	GRPCMethodTimeouts  string        `yaml:"grpc_method_timeouts"` // Метод=время через запятую; остальным - grpc_timeout
+	ShutdownTimeout     time.Duration `yaml:"shutdown_timeout"`
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
+	StatusCodes         bool          `yaml:"status_codes"`
+}
+
+// MethodTimeouts разбирает grpc_method_timeouts вида "GetSavings=10s,StreamSavings=10m"
+func (s Server) MethodTimeouts() (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, item := range strings.Split(s.GRPCMethodTimeouts, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		method, value, ok := strings.Cut(item, "=")
+		method = strings.TrimSpace(method)
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || method == "" || err != nil || d <= 0 {
+			return nil, fmt.Errorf("ожидается Метод=длительность, получено %q", item)
+		}
+		timeouts[method] = d
+	}
+	return timeouts, nil
+}
+
+// TLS - сертификат для gRPC и gRPC-Web; без него серверы работают без шифрования
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// Enabled - задан ли сертификат
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// ClickHouse - подключение, реплики, пул соединений и повторы
+type ClickHouse struct {
+	Host             string        `yaml:"host"`
+	Port             string        `yaml:"port"`
+	Hosts            string        `yaml:"hosts"` // реплики host:port через запятую; если заданы, host и port не используются
+	ConnOpenStrategy string        `yaml:"conn_open_strategy"`
+	Database         string        `yaml:"database"`
+	User             string        `yaml:"user"`
+	Password         string        `yaml:"password"`
+	MaxOpenConns     int           `yaml:"max_open_conns"`
+	MaxIdleConns     int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime"`
+	DialTimeout      time.Duration `yaml:"dial_timeout"`
+	Timeout          time.Duration `yaml:"timeout"`
+	TLS              bool          `yaml:"tls"`
+	TLSCAFile        string        `yaml:"tls_ca_file"`
+	TLSSkipVerify    bool          `yaml:"tls_skip_verify"`
+	ConnectAttempts  int           `yaml:"connect_attempts"` // попытки подключения при запуске
+	QueryAttempts    int           `yaml:"query_attempts"`   // попытки запроса при сетевых ошибках
+	RetryBackoff     time.Duration `yaml:"retry_backoff"`
+	RetryMaxBackoff  time.Duration `yaml:"retry_max_backoff"`
+	AutoMigrate      bool          `yaml:"auto_migrate"`   // применять миграции схемы при запуске
+	SavingsSource    string        `yaml:"savings_source"` // raw - события, aggregate - почасовые агрегаты
+}
+
+// Addrs - адреса реплик: из hosts или единственный host:port
+func (c ClickHouse) Addrs() []string {
+	if strings.TrimSpace(c.Hosts) == "" {
+		return []string{net.JoinHostPort(c.Host, c.Port)}
+	}
+	var addrs []string
+	for _, h := range strings.Split(c.Hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			addrs = append(addrs, h)
+		}
+	}
+	return addrs
+}
+
+// DSN собирает строку подключения к ClickHouse со всеми репликами
+func (c ClickHouse) DSN() string {
+	u := url.URL{
+		Scheme: "clickhouse",
+		Host:   strings.Join(c.Addrs(), ","),
+		Path:   "/" + c.Database,
+	}
+	if c.Password != "" {
+		u.User = url.UserPassword(c.User, c.Password)
+	} else {
+		u.User = url.User(c.User)
+	}
+	q := url.Values{}
+	if c.ConnOpenStrategy != "" && c.ConnOpenStrategy != "in_order" {
+		q.Set("connection_open_strategy", c.ConnOpenStrategy)
+	}
+	if c.TLS {
+		q.Set("secure", "true")
+	}
+	if c.TLSSkipVerify {
+		q.Set("skip_verify", "true")
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Cashback - правила кэшбека: файл с правилами или один процент на все покупки
+type Cashback struct {
+	RulesFile      string  `yaml:"rules_file"`
+	DefaultPercent float64 `yaml:"default_percent"`
+}
+
+// Rates - курсы валют для экономии в валюте, отличной от валюты покупок
+type Rates struct {
+	Source  string        `yaml:"source"`  // none - без курсов, file - файл, clickhouse - таблица exchange_rates
+	File    string        `yaml:"file"`    // YAML/JSON с курсами для source: file
+	Refresh time.Duration `yaml:"refresh"` // как часто перечитывать курсы
+}
+
+// CORS - разрешённые origin для gRPC-Web через запятую
+type CORS struct {
+	AllowedOrigins string `yaml:"allowed_origins"`
+}
+
+// Auth - проверка JWT; без секрета и ключа авторизация выключена
+type Auth struct {
+	JWTHMACSecret       string `yaml:"jwt_hmac_secret"`
+	JWTRSAPublicKeyFile string `yaml:"jwt_rsa_public_key_file"`
+}
+
+// Log - настройки логирования
+type Log struct {
+	Level      string `yaml:"level"`
+	Format     string `yaml:"format"`
+	UserIDMode string `yaml:"user_id_mode"`
+	UserIDSalt string `yaml:"user_id_salt"`
+}
+
+// Default - значения по умолчанию, совпадающие с прежним поведением сервиса
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Host:                "0.0.0.0",
+			GRPCPort:            "50051",
+			WebPort:             "8080",
+			GRPCTimeout:         30 * time.Second,
+			GRPCMethodTimeouts:  "GetSavings=10s,GetCashbackTier=5s,StreamSavings=10m",
+			ShutdownTimeout:     15 * time.Second,
+			HealthCheckInterval: 10 * time.Second,
+		},
+		ClickHouse: ClickHouse{
+			Host:             "localhost",
+			Port:             "9000",
+			Database:         "default",
+			User:             "default",
+			MaxOpenConns:     10,
+			MaxIdleConns:     5,
+			ConnMaxLifetime:  time.Hour,
+			ConnOpenStrategy: "in_order",
+			DialTimeout:      5 * time.Second,
+			Timeout:          30 * time.Second,
+			ConnectAttempts:  10,
+			QueryAttempts:    3,
+			RetryBackoff:     200 * time.Millisecond,
+			RetryMaxBackoff:  5 * time.Second,
+			SavingsSource:    "raw",
+		},
+		Cashback: Cashback{DefaultPercent: 3},
+		Rates:    Rates{Source: "none", Refresh: 10 * time.Minute},
+		CORS:     CORS{AllowedOrigins: "http://localhost:3000"},
+		Log: Log{
+			Level:      "info",
+			Format:     "json",
+			UserIDMode: "hash",
+		},
+	}
+}
+
+// binding связывает поле конфигурации с переменной окружения и флагом
+type binding struct {
+	env   string
+	flag  string
+	usage string
+	ptr   any // *string, *int, *bool, *float64 или *time.Duration
+}
+
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"GRPC_HOST", "host", "адрес, на котором слушают серверы", &c.Server.Host},
+		{"GRPC_PORT", "grpc-port", "порт gRPC", &c.Server.GRPCPort},
+		{"WEB_PORT", "web-port", "порт gRPC-Web", &c.Server.WebPort},
+		{"GRPC_TIMEOUT", "grpc-timeout", "предельное время обработки вызова", &c.Server.GRPCTimeout},
+		{"GRPC_METHOD_TIMEOUTS", "grpc-method-timeouts", "предельное время отдельных методов: GetSavings=10s,StreamSavings=10m", &c.Server.GRPCMethodTimeouts},
+		{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "сколько ждать завершения запросов при остановке", &c.Server.ShutdownTimeout},
+		{"HEALTH_CHECK_INTERVAL", "health-check-interval", "как часто проверять ClickHouse", &c.Server.HealthCheckInterval},
+		{"GRPC_STATUS_CODES", "grpc-status-codes", "возвращать ошибки gRPC-кодами", &c.Server.StatusCodes},
+		{"TLS_CERT_FILE", "tls-cert", "сертификат TLS (PEM)", &c.TLS.CertFile},
+		{"TLS_KEY_FILE", "tls-key", "закрытый ключ TLS (PEM)", &c.TLS.KeyFile},
+		{"CLICKHOUSE_HOST", "clickhouse-host", "хост ClickHouse", &c.ClickHouse.Host},
+		{"CLICKHOUSE_PORT", "clickhouse-port", "порт ClickHouse (native)", &c.ClickHouse.Port},
+		{"CLICKHOUSE_HOSTS", "clickhouse-hosts", "реплики ClickHouse host:port через запятую", &c.ClickHouse.Hosts},
+		{"CLICKHOUSE_CONN_OPEN_STRATEGY", "clickhouse-conn-open-strategy", "выбор реплики: in_order, round_robin или random", &c.ClickHouse.ConnOpenStrategy},
+		{"CLICKHOUSE_DB", "clickhouse-db", "база данных ClickHouse", &c.ClickHouse.Database},
+		{"CLICKHOUSE_USER", "clickhouse-user", "пользователь ClickHouse", &c.ClickHouse.User},
+		{"CLICKHOUSE_PASSWORD", "clickhouse-password", "пароль ClickHouse", &c.ClickHouse.Password},
+		{"CLICKHOUSE_MAX_OPEN_CONNS", "clickhouse-max-open-conns", "максимум открытых соединений", &c.ClickHouse.MaxOpenConns},
+		{"CLICKHOUSE_MAX_IDLE_CONNS", "clickhouse-max-idle-conns", "максимум простаивающих соединений", &c.ClickHouse.MaxIdleConns},
+		{"CLICKHOUSE_CONN_MAX_LIFETIME", "clickhouse-conn-max-lifetime", "время жизни соединения", &c.ClickHouse.ConnMaxLifetime},
+		{"CLICKHOUSE_DIAL_TIMEOUT", "clickhouse-dial-timeout", "время на установку соединения", &c.ClickHouse.DialTimeout},
+		{"CLICKHOUSE_TLS", "clickhouse-tls", "подключаться к ClickHouse по TLS", &c.ClickHouse.TLS},
+		{"CLICKHOUSE_TLS_CA_FILE", "clickhouse-tls-ca", "корневой сертификат ClickHouse (PEM)", &c.ClickHouse.TLSCAFile},
+		{"CLICKHOUSE_TLS_SKIP_VERIFY", "clickhouse-tls-skip-verify", "не проверять сертификат ClickHouse", &c.ClickHouse.TLSSkipVerify},
+		{"CLICKHOUSE_CONNECT_ATTEMPTS", "clickhouse-connect-attempts", "попытки подключения при запуске", &c.ClickHouse.ConnectAttempts},
+		{"CLICKHOUSE_QUERY_ATTEMPTS", "clickhouse-query-attempts", "попытки запроса при сетевых ошибках", &c.ClickHouse.QueryAttempts},
+		{"CLICKHOUSE_RETRY_BACKOFF", "clickhouse-retry-backoff", "пауза перед первым повтором", &c.ClickHouse.RetryBackoff},
+		{"CLICKHOUSE_RETRY_MAX_BACKOFF", "clickhouse-retry-max-backoff", "предел паузы между повторами", &c.ClickHouse.RetryMaxBackoff},
+		{"CLICKHOUSE_AUTO_MIGRATE", "clickhouse-auto-migrate", "применять миграции схемы при запуске", &c.ClickHouse.AutoMigrate},
+		{"SAVINGS_SOURCE", "savings-source", "источник расчёта экономии: raw или aggregate", &c.ClickHouse.SavingsSource},
+		{"DB_TIMEOUT", "db-timeout", "предельное время запроса к ClickHouse", &c.ClickHouse.Timeout},
+		{"CASHBACK_RULES_FILE", "cashback-rules", "файл с правилами кэшбека (YAML/JSON)", &c.Cashback.RulesFile},
+		{"CASHBACK_DEFAULT_PERCENT", "cashback-default-percent", "процент кэшбека, если файл правил не задан", &c.Cashback.DefaultPercent},
+		{"RATES_SOURCE", "rates-source", "источник курсов валют: none, file или clickhouse", &c.Rates.Source},
+		{"RATES_FILE", "rates-file", "файл с курсами валют (YAML/JSON)", &c.Rates.File},
+		{"RATES_REFRESH", "rates-refresh", "как часто перечитывать курсы валют", &c.Rates.Refresh},
+		{"CORS_ALLOWED_ORIGINS", "cors-allowed-origins", "разрешённые origin через запятую", &c.CORS.AllowedOrigins},
+		{"AUTH_JWT_HMAC_SECRET", "auth-jwt-hmac-secret", "HMAC-секрет для JWT", &c.Auth.JWTHMACSecret},
+		{"AUTH_JWT_RSA_PUBLIC_KEY_FILE", "auth-jwt-rsa-public-key", "открытый RSA-ключ для JWT (PEM)", &c.Auth.JWTRSAPublicKeyFile},
+		{"LOG_LEVEL", "log-level", "уровень логов: debug, info, warn, error", &c.Log.Level},
+		{"LOG_FORMAT", "log-format", "формат логов: json или text", &c.Log.Format},
+		{"LOG_USER_ID_MODE", "log-user-id-mode", "user_id в логах: hash, mask или plain", &c.Log.UserIDMode},
+		{"LOG_USER_ID_SALT", "log-user-id-salt", "соль для хэша user_id", &c.Log.UserIDSalt},
+		{"DEBUG", "debug", "режим отладки: логи уровня debug", &c.Debug},
+	}
+}
+
+// Load собирает конфигурацию из всех источников и проверяет её.
+// lookupEnv - обычно os.LookupEnv; в тестах подменяется.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	bindings := cfg.bindings()
+
+	// Флаги разбираем первыми, чтобы узнать путь к файлу, но применяем последними
+	fs := flag.NewFlagSet("money-service", flag.ContinueOnError)
+	configFile := fs.String("config", "", "YAML-файл конфигурации (или CONFIG_FILE)")
+	flagValues := map[string]string{}
+	for _, b := range bindings {
+		name, usage := b.flag, fmt.Sprintf("%s (%s)", b.usage, b.env)
+		record := func(v string) error {
+			flagValues[name] = v
+			return nil
+		}
+		if _, ok := b.ptr.(*bool); ok {
+			fs.BoolFunc(name, usage, record)
+		} else {
+			fs.Func(name, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.Args = fs.Args()
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, b := range bindings {
+		if v, ok := lookupEnv(b.env); ok && v != "" {
+			if err := set(b.ptr, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", b.env, err))
+			}
+		}
+	}
+	for _, b := range bindings {
+		if v, ok := flagValues[b.flag]; ok {
+			if err := set(b.ptr, v); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", b.flag, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// Режим отладки включает подробные логи, даже если уровень задан явно
+	if cfg.Debug && cfg.Log.Level != "debug" {
+		envLevel, _ := lookupEnv("LOG_LEVEL")
+		_, flagLevel := flagValues["log-level"]
+		if envLevel != "" || flagLevel || cfg.Log.Level != Default().Log.Level {
+			cfg.Warnings = append(cfg.Warnings,
+				fmt.Sprintf("debug включает логи уровня debug вместо заданного log.level %q", cfg.Log.Level))
+		}
+		cfg.Log.Level = "debug"
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile читает YAML; неизвестные ключи - ошибка, чтобы опечатки не проходили молча
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("чтение конфигурации: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("разбор конфигурации %s: %w", path, err)
+	}
+	return nil
+}
+
+func set(ptr any, v string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = v
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("ожидается целое число, получено %q", v)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("ожидается true или false, получено %q", v)
+		}
+		*p = b
+	case *float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("ожидается число, получено %q", v)
+		}
+		*p = f
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("ожидается длительность вида 30s, получено %q", v)
+		}
+		*p = d
+	default:
+		return fmt.Errorf("неподдерживаемый тип %T", ptr)
+	}
+	return nil
+}
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.GRPCPort), "server.grpc_port: некорректный порт %q", c.Server.GRPCPort)
+	check(validPort(c.Server.WebPort), "server.web_port: некорректный порт %q", c.Server.WebPort)
+	check(c.Server.GRPCPort != c.Server.WebPort, "server: gRPC и gRPC-Web не могут слушать один порт %s", c.Server.GRPCPort)
+	check(c.Server.GRPCTimeout > 0, "server.grpc_timeout должен быть больше нуля")
+	if _, err := c.Server.MethodTimeouts(); err != nil {
+		errs = append(errs, fmt.Errorf("server.grpc_method_timeouts: %w", err))
+	}
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout должен быть больше нуля")
+	check(c.Server.HealthCheckInterval > 0, "server.health_check_interval должен быть больше нуля")
+
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls: cert_file и key_file задаются вместе")
+
+	if strings.TrimSpace(c.ClickHouse.Hosts) == "" {
+		check(c.ClickHouse.Host != "", "clickhouse.host не задан")
+		check(validPort(c.ClickHouse.Port), "clickhouse.port: некорректный порт %q", c.ClickHouse.Port)
+	} else {
+		for _, addr := range c.ClickHouse.Addrs() {
+			host, port, err := net.SplitHostPort(addr)
+			check(err == nil && host != "" && validPort(port), "clickhouse.hosts: ожидается host:port, получено %q", addr)
+		}
+	}
+	switch c.ClickHouse.ConnOpenStrategy {
+	case "in_order", "round_robin", "random":
+	default:
+		errs = append(errs, fmt.Errorf("clickhouse.conn_open_strategy: ожидается in_order, round_robin или random, получено %q", c.ClickHouse.ConnOpenStrategy))
+	}
+	check(c.ClickHouse.Database != "", "clickhouse.database не задана")
+	check(c.ClickHouse.MaxOpenConns > 0, "clickhouse.max_open_conns должен быть больше нуля")
+	check(c.ClickHouse.MaxIdleConns >= 0 && c.ClickHouse.MaxIdleConns <= c.ClickHouse.MaxOpenConns,
+		"clickhouse.max_idle_conns должен быть от 0 до max_open_conns (%d)", c.ClickHouse.MaxOpenConns)
+	check(c.ClickHouse.ConnMaxLifetime >= 0, "clickhouse.conn_max_lifetime не может быть отрицательным")
+	check(c.ClickHouse.DialTimeout > 0, "clickhouse.dial_timeout должен быть больше нуля")
+	check(c.ClickHouse.Timeout > 0, "clickhouse.timeout должен быть больше нуля")
+	check(c.ClickHouse.TLS || c.ClickHouse.TLSCAFile == "" && !c.ClickHouse.TLSSkipVerify,
+		"clickhouse: tls_ca_file и tls_skip_verify действуют только при tls: true")
+	switch c.ClickHouse.SavingsSource {
+	case "raw", "aggregate":
+	default:
+		errs = append(errs, fmt.Errorf("clickhouse.savings_source: ожидается raw или aggregate, получено %q", c.ClickHouse.SavingsSource))
+	}
+	check(c.ClickHouse.ConnectAttempts > 0, "clickhouse.connect_attempts должен быть больше нуля")
+	check(c.ClickHouse.QueryAttempts > 0, "clickhouse.query_attempts должен быть больше нуля")
+	check(c.ClickHouse.RetryBackoff > 0 && c.ClickHouse.RetryBackoff <= c.ClickHouse.RetryMaxBackoff,
+		"clickhouse.retry_backoff должен быть больше нуля и не больше retry_max_backoff")
+
+	check(c.Cashback.DefaultPercent >= 0 && c.Cashback.DefaultPercent <= 100,
+		"cashback.default_percent должен быть от 0 до 100, получено %v", c.Cashback.DefaultPercent)
+
+	switch c.Rates.Source {
+	case "none", "clickhouse":
+		check(c.Rates.File == "", "rates.file действует только при source: file")
+	case "file":
+		check(c.Rates.File != "", "rates.file обязателен при source: file")
+	default:
+		errs = append(errs, fmt.Errorf("rates.source: ожидается none, file или clickhouse, получено %q", c.Rates.Source))
+	}
+	check(c.Rates.Refresh > 0, "rates.refresh должен быть больше нуля")
+
+	if _, err := cors.ParseAllowList(c.CORS.AllowedOrigins); err != nil {
+		errs = append(errs, fmt.Errorf("cors.allowed_origins: %w", err))
+	}
+
+	check(c.Auth.JWTHMACSecret == "" || c.Auth.JWTRSAPublicKeyFile == "",
+		"auth: jwt_hmac_secret и jwt_rsa_public_key_file взаимоисключающие")
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: ожидается debug, info, warn или error, получено %q", c.Log.Level))
+	}
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("log.format: ожидается json или text, получено %q", c.Log.Format))
+	}
+	switch c.Log.UserIDMode {
+	case "plain", "hash", "mask":
+	default:
+		errs = append(errs, fmt.Errorf("log.user_id_mode: ожидается plain, hash или mask, получено %q", c.Log.UserIDMode))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validPort(p string) bool {
+	n, err := strconv.Atoi(p)
+	return err == nil && n > 0 && n <= 65535
+}
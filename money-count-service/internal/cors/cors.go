@@ -0,0 +1,119 @@
+package cors
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	allowMethods = "POST, GET, OPTIONS"
+	allowHeaders = "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Grpc-Web, X-User-Agent, Traceparent, Tracestate"
+)
+
+// AllowList - список разрешённых origin: точные ("https://wb.ru") и с поддоменами ("https://*.wb.ru").
+// "*" разрешает всё и нужен только для локальной разработки.
+type AllowList struct {
+	allowAll  bool
+	exact     map[string]bool
+	wildcards []wildcard
+	rejected  atomic.Uint64
+}
+
+// wildcard - "https://*.wb.ru": схема и суффикс хоста; сам wb.ru под него не подпадает
+type wildcard struct {
+	scheme string
+	suffix string
+}
+
+// ParseAllowList разбирает список origin через запятую
+func ParseAllowList(spec string) (*AllowList, error) {
+	a := &AllowList{exact: map[string]bool{}}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if item == "*" {
+			a.allowAll = true
+			continue
+		}
+
+		u, err := url.Parse(strings.ToLower(item))
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("некорректный origin %q: ожидается схема://хост[:порт]", item)
+		}
+		if rest, ok := strings.CutPrefix(u.Host, "*."); ok {
+			if rest == "" || strings.Contains(rest, "*") {
+				return nil, fmt.Errorf("некорректный шаблон origin %q", item)
+			}
+			a.wildcards = append(a.wildcards, wildcard{scheme: u.Scheme, suffix: "." + rest})
+			continue
+		}
+		if strings.Contains(u.Host, "*") {
+			return nil, fmt.Errorf("звёздочка допустима только в начале хоста: %q", item)
+		}
+		a.exact[u.Scheme+"://"+u.Host] = true
+	}
+	return a, nil
+}
+
+// Allowed проверяет origin из заголовка запроса
+func (a *AllowList) Allowed(origin string) bool {
+	if a.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if a.exact[origin] {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	for _, w := range a.wildcards {
+		if u.Scheme == w.scheme && strings.HasSuffix(u.Host, w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rejected - сколько запросов отклонено из-за origin с момента запуска
+func (a *AllowList) Rejected() uint64 {
+	return a.rejected.Load()
+}
+
+// Middleware проверяет origin одинаково для preflight и обычных запросов и сама отвечает на preflight.
+// Запросы без Origin (не из браузера: healthcheck, gRPC-клиенты) пропускаются.
+func (a *AllowList) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !a.Allowed(origin) {
+			a.rejected.Add(1)
+			slog.WarnContext(r.Context(), "CORS origin rejected",
+				slog.String("origin", origin), slog.String("method", r.Method), slog.String("path", r.URL.Path))
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
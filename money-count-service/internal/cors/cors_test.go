@@ -0,0 +1,91 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Qwental/wb-money/internal/cors"
+)
+
+func TestAllowListAllowed(t *testing.T) {
+	a, err := cors.ParseAllowList("http://localhost:3000, https://*.wildberries.ru")
+	if err != nil {
+		t.Fatalf("ParseAllowList: %v", err)
+	}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"http://localhost:3000", true},
+		{"HTTP://LOCALHOST:3000", true},
+		{"http://localhost:3001", false},
+		{"https://seller.wildberries.ru", true},
+		{"https://a.b.wildberries.ru", true},
+		{"https://wildberries.ru", false},
+		{"http://seller.wildberries.ru", false},
+		{"https://evilwildberries.ru", false},
+		{"https://wildberries.ru.evil.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := a.Allowed(tt.origin); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestParseAllowListErrors(t *testing.T) {
+	for _, spec := range []string{"localhost:3000", "https://wb.ru/path", "https://a.*.wb.ru", "https://*."} {
+		if _, err := cors.ParseAllowList(spec); err == nil {
+			t.Errorf("ParseAllowList(%q) accepted invalid origin", spec)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a, err := cors.ParseAllowList("http://localhost:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := a.Middleware(next)
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		wantStatus int
+	}{
+		{"allowed preflight", http.MethodOptions, "http://localhost:3000", http.StatusOK},
+		{"rejected preflight", http.MethodOptions, "https://evil.com", http.StatusForbidden},
+		{"allowed request", http.MethodPost, "http://localhost:3000", http.StatusTeapot},
+		{"rejected request", http.MethodPost, "https://evil.com", http.StatusForbidden},
+		{"no origin", http.MethodGet, "", http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/money_service.MoneyService/GetSavings", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.name == "allowed preflight" && rec.Header().Get("Access-Control-Allow-Origin") != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q", rec.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+
+	if got := a.Rejected(); got != 2 {
+		t.Errorf("Rejected = %d, want 2", got)
+	}
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/money"
+)
+
+// aggregateStep - шаг времени таблицы user_purchases_hourly
+const aggregateStep = time.Hour
+
+// Имена запросов к агрегатам в метриках и трейсах
+const (
+	queryHourlyAggregate = "hourly_savings_aggregate"
+	queryAggregateCheck  = "aggregate_consistency_check"
+)
+
+// hourlySavingsAggregateQuery - то же, что savingsAggregateQuery, но по почасовым агрегатам: покупки
+// уже сгруппированы по способу оплаты, категории и валюте, поэтому вместо подсчёта строк суммируется purchases.
+// Колонка hour выступает как timestamp, чтобы условия периода и правил остались прежними.
+// Пустая валюта - строки, накопленные до появления колонки currency, они рублёвые.
+// refund_events показывает, что у пользователя есть отмены: их агрегаты не учитывают.
+const hourlySavingsAggregateQuery = `
+	SELECT
+		user_id,
+		missed_rule,
+		buy_currency,
+		missed_day,
+		sumIf(purchases, in_period) AS total_purchases,
+		sumIf(purchases, in_period AND payment_method = 'wallet') AS wallet_purchases,
+		sumIf(purchases, payment_method = 'wallet') AS wallet_orders,
+		sumIf(purchases, is_missed) AS missed_purchases,
+		sumIf(amount, is_missed) AS missed_amount,
+		toUInt64(0) AS malformed_rows,
+		sum(refunds) AS refund_events
+	FROM (
+		SELECT
+			user_id,
+			hour AS timestamp,
+			payment_method,
+			category,
+			purchases,
+			refunds,
+			amount_minor AS amount,
+			%s AS in_period,
+			%s AS rule_id,
+			purchases > 0 AND in_period AND payment_method != 'wallet' AND rule_id != '' AS is_missed,
+			if(is_missed, rule_id, '') AS missed_rule,
+			if(purchases > 0, if(currency = '', '` + models.DefaultCurrency + `', currency), '') AS buy_currency,
+			if(is_missed, toDate(timestamp, 'UTC'), toDate(0)) AS missed_day
+		FROM user_purchases_hourly
+		WHERE user_id IN (%s)
+	)
+	GROUP BY user_id, missed_rule, buy_currency, missed_day
+`
+
+// hourlyAggregatable сообщает, что экономию за период можно посчитать по почасовым агрегатам
+// без потери точности: границы периода и акций кратны часу, а правила не смотрят на сумму покупки
+func hourlyAggregatable(rules *cashback.RuleSet, period TimeRange) bool {
+	aligned := func(t time.Time) bool { return t.IsZero() || t.Truncate(aggregateStep).Equal(t) }
+	return aligned(period.From) && aligned(period.To) && rules.Aggregatable(aggregateStep)
+}
+
+// hourlySavingsAggregates - SavingsAggregates по таблице user_purchases_hourly. Отмена заказа
+// убирает покупку из часа, в котором она сделана, а в агрегатах покупки уже без order_id:
+// пользователей с отменами пересчитываем по сырым событиям.
+func (s *ClickHouseStore) hourlySavingsAggregates(ctx context.Context, rules *cashback.RuleSet, userIDs []uint64,
+	period TimeRange) (map[uint64]*SavingsAggregate, error) {
+	periodCond, periodArgs := period.condition()
+	ruleID := rules.RuleIDExpr()
+	query := fmt.Sprintf(hourlySavingsAggregateQuery, periodCond, ruleID.SQL, placeholders(len(userIDs)))
+
+	args := append(periodArgs, ruleID.Args...)
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+
+	var rows []savingsAggregateRow
+	if err := s.selectContext(ctx, queryHourlyAggregate, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	withRefunds := map[uint64]bool{}
+	for _, row := range rows {
+		if row.RefundEvents > 0 {
+			withRefunds[row.UserID] = true
+		}
+	}
+	refunded := slices.Sorted(maps.Keys(withRefunds))
+	aggs := foldSavingsAggregates(rows)
+	if len(refunded) == 0 {
+		return aggs, nil
+	}
+
+	logging.FromContext(ctx).Debug("users with cancelled orders, reading product_events", "users", len(refunded))
+	raw, err := s.rawSavingsAggregates(ctx, rules, refunded, period)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range refunded {
+		delete(aggs, id)
+		if agg, ok := raw[id]; ok {
+			aggs[id] = agg
+		}
+	}
+	return aggs, nil
+}
+
+// aggregateCheckQuery сравнивает по пользователям сырые события с почасовыми агрегатами и
+// возвращает расходящихся. Плейсхолдеры %s: условие периода для событий и для агрегатов.
+const aggregateCheckQuery = `
+	SELECT
+		user_id,
+		r.events AS raw_events,
+		a.events AS agg_events,
+		r.purchases AS raw_purchases,
+		a.purchases AS agg_purchases,
+		r.wallet_purchases AS raw_wallet_purchases,
+		a.wallet_purchases AS agg_wallet_purchases,
+		r.amount AS raw_amount,
+		a.amount AS agg_amount,
+		count() OVER () AS mismatched_users
+	FROM (
+		SELECT
+			user_id,
+			count() AS events,
+			countIf(is_buy) AS purchases,
+			countIf(is_buy AND JSONExtractString(parameters, 'payment_method') = 'wallet') AS wallet_purchases,
+			sumIf(` + amountMinor + `, is_buy) AS amount
+		FROM (
+			SELECT user_id, timestamp, parameters, event_name = 'buy' AND isValidJSON(parameters) AS is_buy
+			FROM product_events
+		)
+		WHERE %s
+		GROUP BY user_id
+	) AS r
+	FULL OUTER JOIN (
+		SELECT
+			user_id,
+			sum(events) AS events,
+			sum(purchases) AS purchases,
+			sumIf(purchases, payment_method = 'wallet') AS wallet_purchases,
+			sum(amount_minor) AS amount
+		FROM (SELECT *, hour AS timestamp FROM user_purchases_hourly)
+		WHERE %s
+		GROUP BY user_id
+	) AS a USING (user_id)
+	WHERE raw_events != agg_events
+		OR raw_purchases != agg_purchases
+		OR raw_wallet_purchases != agg_wallet_purchases
+		OR raw_amount != agg_amount
+	ORDER BY user_id
+	LIMIT ?
+`
+
+// AggregateMismatch - пользователь, у которого агрегаты не сходятся с событиями
+type AggregateMismatch struct {
+	UserID             uint64       `db:"user_id"`
+	RawEvents          uint64       `db:"raw_events"`
+	AggEvents          uint64       `db:"agg_events"`
+	RawPurchases       uint64       `db:"raw_purchases"`
+	AggPurchases       uint64       `db:"agg_purchases"`
+	RawWalletPurchases uint64       `db:"raw_wallet_purchases"`
+	AggWalletPurchases uint64       `db:"agg_wallet_purchases"`
+	RawAmount          money.Amount `db:"raw_amount"`
+	AggAmount          money.Amount `db:"agg_amount"`
+	MismatchedUsers    uint64       `db:"mismatched_users"`
+}
+
+// CheckAggregates сравнивает user_purchases_hourly с расчётом по product_events за период
+// и возвращает не больше limit расходящихся пользователей вместе с их общим числом.
+// Период должен быть кратен часу, иначе агрегаты захватят лишние события.
+func (s *ClickHouseStore) CheckAggregates(ctx context.Context, period TimeRange, limit int) ([]AggregateMismatch, uint64, error) {
+	if !hourlyAggregatable(&cashback.RuleSet{}, period) {
+		return nil, 0, fmt.Errorf("границы периода должны быть кратны часу: %v - %v", period.From, period.To)
+	}
+	cond, condArgs := period.condition()
+	query := fmt.Sprintf(aggregateCheckQuery, cond, cond)
+	args := append(append(append([]any{}, condArgs...), condArgs...), limit)
+
+	var rows []AggregateMismatch
+	if err := s.selectContext(ctx, queryAggregateCheck, &rows, query, args...); err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return nil, 0, nil
+	}
+	return rows, rows[0].MismatchedUsers, nil
+}
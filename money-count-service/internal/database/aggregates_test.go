@@ -0,0 +1,20 @@
+package database_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/database"
+)
+
+func TestCheckAggregatesRejectsUnalignedPeriod(t *testing.T) {
+	store := database.NewClickHouseStore(nil, database.StoreOptions{})
+	from := time.Date(2025, time.May, 1, 10, 30, 0, 0, time.UTC)
+
+	_, _, err := store.CheckAggregates(context.Background(), database.TimeRange{From: from}, 10)
+	if err == nil || !strings.Contains(err.Error(), "кратны часу") {
+		t.Errorf("error = %v, want unaligned period error", err)
+	}
+}
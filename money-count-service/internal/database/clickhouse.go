@@ -1,10 +1,46 @@
 package database
 
 import (
-	_ "github.com/ClickHouse/clickhouse-go/v2"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/jmoiron/sqlx"
 )
 
+// Стратегии выбора реплики при открытии соединения
+const (
+	StrategyInOrder    = "in_order"    // первая доступная по порядку, остальные - запасные
+	StrategyRoundRobin = "round_robin" // по очереди, нагрузка распределяется между репликами
+	StrategyRandom     = "random"
+)
+
+var strategies = map[string]clickhouse.ConnOpenStrategy{
+	StrategyInOrder:    clickhouse.ConnOpenInOrder,
+	StrategyRoundRobin: clickhouse.ConnOpenRoundRobin,
+	StrategyRandom:     clickhouse.ConnOpenRandom,
+}
+
+// Config - подключение к ClickHouse: реплики, пул соединений, TLS и повторы
+type Config struct {
+	Addrs            []string // host:port реплик; недоступная реплика пропускается при открытии соединения
+	Database         string
+	User             string
+	Password         string
+	ConnOpenStrategy string
+	DialTimeout      time.Duration
+	MaxOpenConns     int
+	MaxIdleConns     int
+	ConnMaxLifetime  time.Duration
+	TLS              *tls.Config // nil - без шифрования
+	Retry            Retry       // повторы при запуске, пока ClickHouse поднимается
+}
+
 func NewClickHouseDB(url string) (*sqlx.DB, error) {
 	db, err := sqlx.Open("clickhouse", url)
 	if err != nil {
@@ -17,3 +53,66 @@ func NewClickHouseDB(url string) (*sqlx.DB, error) {
 
 	return db, nil
 }
+
+// Open создаёт пул соединений и дожидается ClickHouse: сетевые ошибки при первом Ping
+// повторяются с backoff по cfg.Retry, ошибки авторизации и конфигурации - нет.
+// onRetry вызывается перед каждым повтором, например для логирования; может быть nil.
+func Open(ctx context.Context, cfg Config, onRetry func(attempt int, err error)) (*sqlx.DB, error) {
+	strategy, ok := strategies[cfg.ConnOpenStrategy]
+	if !ok && cfg.ConnOpenStrategy != "" {
+		return nil, fmt.Errorf("неизвестная стратегия подключения %q", cfg.ConnOpenStrategy)
+	}
+
+	opts := &clickhouse.Options{
+		Addr: cfg.Addrs,
+		Auth: clickhouse.Auth{
+			Database: cfg.Database,
+			Username: cfg.User,
+			Password: cfg.Password,
+		},
+		TLS:              cfg.TLS,
+		DialTimeout:      cfg.DialTimeout,
+		ConnOpenStrategy: strategy,
+	}
+	db := sqlx.NewDb(clickhouse.OpenDB(opts), "clickhouse")
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	attempt := 0
+	err := cfg.Retry.Do(ctx, func() error {
+		attempt++
+		return db.PingContext(ctx)
+	}, func(err error) {
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("подключение к ClickHouse %v: %w", cfg.Addrs, err)
+	}
+	return db, nil
+}
+
+// TLSConfig собирает настройки TLS для ClickHouse. caFile - свой корневой сертификат (PEM),
+// пустой - системные; skipVerify отключает проверку сертификата и нужен только для отладки.
+func TLSConfig(caFile string, skipVerify bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: skipVerify, //nolint:gosec // включается явно для тестовых стендов
+	}
+	if caFile == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("чтение CA для ClickHouse: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("в CA для ClickHouse нет сертификатов")
+	}
+	cfg.RootCAs = pool
+	return cfg, nil
+}
@@ -0,0 +1,348 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/internal/metrics"
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/money"
+	"github.com/jmoiron/sqlx"
+)
+
+// Имена запросов к ClickHouse в метриках и трейсах
+const (
+	querySavingsAggregate = "savings_aggregate"
+	queryPurchases        = "purchases"
+	queryWalletStats      = "wallet_stats"
+	queryCohortPage       = "cohort_page"
+)
+
+// amountMinor - сумма покупки из parameters в копейках. Округление к чётному, как в money.FromDecimal,
+// поэтому суммы с двумя знаками после запятой переводятся точно.
+const amountMinor = `toInt64(round(JSONExtractFloat(parameters, 'amount') * 100))`
+
+// buyCurrency - валюта покупки из parameters; без неё покупка считается рублёвой
+const buyCurrency = `coalesce(nullIf(JSONExtractString(parameters, 'currency'), ''), '` + models.DefaultCurrency + `')`
+
+// notCancelled - покупка не отменена и не возвращена: её order_id не встречается в событиях refund
+// и cancel того же пользователя. Отменяется весь заказ, когда бы ни пришла отмена, поэтому покупка
+// выпадает и из периода, в который попала. Покупки без order_id отменить нельзя.
+// Плейсхолдер %s - условие на user_id для подзапроса отмен.
+const notCancelled = `(user_id, JSONExtractString(parameters, 'order_id')) NOT IN (
+			SELECT user_id, JSONExtractString(parameters, 'order_id')
+			FROM product_events
+			WHERE %s AND event_name IN ('refund', 'cancel') AND isValidJSON(parameters)
+				AND JSONExtractString(parameters, 'order_id') != ''
+		)`
+
+// savingsAggregateQuery считает всё необходимое для GetSavingsResponse за один проход по событиям пользователей.
+// Строки сгруппированы по правилу, валюте и дню упущенных покупок, чтобы сервис мог перевести суммы
+// по курсу на дату покупки; счётчики складываются по строкам пользователя в foldSavingsAggregates.
+// Пользователь без единого события в результат не попадает. Уровень кэшбека считается по всем событиям,
+// покупки - только внутри периода; отменённые и возвращённые заказы не считаются нигде. Упущенной
+// считается покупка внутри периода, оплаченная не кошельком и подошедшая под правило кэшбека.
+// Плейсхолдеры %s: условие на user_id для отмен, условие периода, выражение id правила кэшбека
+// и список плейсхолдеров для user_id.
+const savingsAggregateQuery = `
+	SELECT
+		user_id,
+		missed_rule,
+		buy_currency,
+		missed_day,
+		countIf(is_buy AND in_period) AS total_purchases,
+		countIf(is_buy AND in_period AND payment_method = 'wallet') AS wallet_purchases,
+		countIf(is_buy AND payment_method = 'wallet') AS wallet_orders,
+		countIf(is_missed) AS missed_purchases,
+		sumIf(amount, is_missed) AS missed_amount,
+		countIf(is_malformed) AS malformed_rows
+	FROM (
+		SELECT
+			user_id,
+			NOT isValidJSON(parameters) AS is_malformed,
+			event_name = 'buy' AND NOT is_malformed AND ` + notCancelled + ` AS is_buy,
+			%s AS in_period,
+			timestamp,
+			` + amountMinor + ` AS amount,
+			JSONExtractString(parameters, 'payment_method') AS payment_method,
+			JSONExtractString(parameters, 'category') AS category,
+			` + buyCurrency + ` AS currency,
+			%s AS rule_id,
+			is_buy AND in_period AND payment_method != 'wallet' AND rule_id != '' AS is_missed,
+			if(is_missed, rule_id, '') AS missed_rule,
+			if(is_buy, currency, '') AS buy_currency,
+			if(is_missed, toDate(timestamp, 'UTC'), toDate(0)) AS missed_day
+		FROM product_events
+		WHERE user_id IN (%s)
+	)
+	GROUP BY user_id, missed_rule, buy_currency, missed_day
+`
+
+// purchasesQuery выбирает неотменённые покупки пользователя за период.
+// Плейсхолдеры %s: условие периода и условие на user_id для отмен.
+const purchasesQuery = `
+	SELECT
+		timestamp,
+		` + amountMinor + ` AS amount,
+		` + buyCurrency + ` AS currency,
+		JSONExtractString(parameters, 'payment_method') AS payment_method,
+		JSONExtractString(parameters, 'category') AS category
+	FROM product_events
+	WHERE user_id = ? AND event_name = 'buy' AND isValidJSON(parameters) AND (%s)
+		AND ` + notCancelled + `
+	ORDER BY timestamp
+`
+
+// walletStatsQuery проверяет существование пользователя и считает его неотменённые покупки кошельком
+// за всё время. Плейсхолдер %s - условие на user_id для отмен.
+const walletStatsQuery = `
+	SELECT
+		count() > 0 AS user_exists,
+		countIf(event_name = 'buy' AND isValidJSON(parameters)
+			AND JSONExtractString(parameters, 'payment_method') = 'wallet'
+			AND ` + notCancelled + `) AS wallet_orders
+	FROM product_events
+	WHERE user_id = ?
+`
+
+// cohortPageQuery берёт следующих пользователей по возрастанию user_id и отмечает, кто из них
+// входит в когорту. Окно пользователей выбирается до агрегации: по ключу сортировки читаются
+// только события этих пользователей, и страница не сканирует таблицу до конца.
+// Плейсхолдеры %s: условие когорты над группой событий пользователя и cohortWindow.
+const cohortPageQuery = `
+	SELECT user_id, %s AS in_cohort
+	FROM product_events
+	WHERE %s
+	GROUP BY user_id
+	ORDER BY user_id
+`
+
+// cohortWindow - условие на user_id для следующих limit пользователей после afterUserID
+const cohortWindow = `user_id IN (
+		SELECT DISTINCT user_id
+		FROM product_events
+		WHERE user_id > ?
+		ORDER BY user_id
+		LIMIT ?
+	)`
+
+// Source - откуда ClickHouseStore берёт покупки для расчёта экономии
+type Source string
+
+const (
+	SourceRaw       Source = "raw"       // сырые события product_events
+	SourceAggregate Source = "aggregate" // почасовые агрегаты user_purchases_hourly
+)
+
+// StoreOptions - настройки запросов ClickHouseStore
+type StoreOptions struct {
+	QueryTimeout time.Duration // предел одного запроса; 0 - DefaultQueryTimeout
+	Retry        Retry         // повторы запроса при сетевых ошибках
+	Source       Source        // откуда считать агрегаты экономии; пусто - SourceRaw
+}
+
+// ClickHouseStore - EventStore поверх ClickHouse: агрегаты считаются запросами в базе,
+// каждый запрос ограничен по времени, трассируется и попадает в метрики
+type ClickHouseStore struct {
+	db           *sqlx.DB
+	queryTimeout time.Duration
+	retry        Retry
+	source       Source
+}
+
+var _ EventStore = (*ClickHouseStore)(nil)
+
+// NewClickHouseStore создаёт хранилище событий поверх пула соединений
+func NewClickHouseStore(db *sqlx.DB, opts StoreOptions) *ClickHouseStore {
+	if opts.QueryTimeout <= 0 {
+		opts.QueryTimeout = DefaultQueryTimeout
+	}
+	if opts.Source == "" {
+		opts.Source = SourceRaw
+	}
+	return &ClickHouseStore{db: db, queryTimeout: opts.QueryTimeout, retry: opts.Retry, source: opts.Source}
+}
+
+func (s *ClickHouseStore) UserStats(ctx context.Context, userID uint64) (UserStats, error) {
+	var stats UserStats
+	err := s.getContext(ctx, queryWalletStats, &stats, fmt.Sprintf(walletStatsQuery, "user_id = ?"), userID, userID)
+	return stats, err
+}
+
+// purchaseRow - строка результата purchasesQuery
+type purchaseRow struct {
+	Timestamp     time.Time    `db:"timestamp"`
+	Amount        money.Amount `db:"amount"`
+	Currency      string       `db:"currency"`
+	PaymentMethod string       `db:"payment_method"`
+	Category      string       `db:"category"`
+}
+
+func (s *ClickHouseStore) Purchases(ctx context.Context, userID uint64, period TimeRange) ([]cashback.Purchase, error) {
+	periodCond, periodArgs := period.condition()
+	var rows []purchaseRow
+	args := append(append([]any{userID}, periodArgs...), userID)
+	if err := s.selectContext(ctx, queryPurchases, &rows, fmt.Sprintf(purchasesQuery, periodCond, "user_id = ?"),
+		args...); err != nil {
+		return nil, err
+	}
+	purchases := make([]cashback.Purchase, len(rows))
+	for i, row := range rows {
+		purchases[i] = cashback.Purchase(row)
+	}
+	return purchases, nil
+}
+
+// SavingsAggregates одним запросом получает агрегаты по покупкам для всех пользователей.
+// Строки с невалидным JSON в parameters не учитываются, но попадают в метрики.
+// В режиме SourceAggregate читает почасовые агрегаты, если правила и период это позволяют.
+func (s *ClickHouseStore) SavingsAggregates(ctx context.Context, rules *cashback.RuleSet, userIDs []uint64,
+	period TimeRange) (map[uint64]*SavingsAggregate, error) {
+	if s.source == SourceAggregate {
+		if hourlyAggregatable(rules, period) {
+			metrics.SavingsSource.WithLabelValues(string(SourceAggregate)).Inc()
+			return s.hourlySavingsAggregates(ctx, rules, userIDs, period)
+		}
+		logging.FromContext(ctx).Debug("rules or period need raw events, reading product_events")
+	}
+	metrics.SavingsSource.WithLabelValues(string(SourceRaw)).Inc()
+	return s.rawSavingsAggregates(ctx, rules, userIDs, period)
+}
+
+// rawSavingsAggregates - SavingsAggregates по сырым событиям product_events
+func (s *ClickHouseStore) rawSavingsAggregates(ctx context.Context, rules *cashback.RuleSet, userIDs []uint64,
+	period TimeRange) (map[uint64]*SavingsAggregate, error) {
+	periodCond, periodArgs := period.condition()
+	ruleID := rules.RuleIDExpr()
+	users := placeholders(len(userIDs))
+	query := fmt.Sprintf(savingsAggregateQuery, "user_id IN ("+users+")", periodCond, ruleID.SQL, users)
+
+	var args []any
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+	args = append(append(args, periodArgs...), ruleID.Args...)
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+
+	var rows []savingsAggregateRow
+	if err := s.selectContext(ctx, querySavingsAggregate, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	aggs := foldSavingsAggregates(rows)
+	var malformed uint64
+	for _, agg := range aggs {
+		malformed += agg.MalformedRows
+	}
+	if malformed > 0 {
+		metrics.MalformedRows.WithLabelValues(querySavingsAggregate).Add(float64(malformed))
+	}
+	return aggs, nil
+}
+
+// savingsAggregateRow - строка результата запросов агрегатов экономии: счётчики пользователя
+// по одному сочетанию правила, валюты и дня упущенных покупок
+type savingsAggregateRow struct {
+	UserID          uint64       `db:"user_id"`
+	MissedRule      string       `db:"missed_rule"`
+	Currency        string       `db:"buy_currency"`
+	MissedDay       time.Time    `db:"missed_day"`
+	TotalPurchases  uint64       `db:"total_purchases"`
+	WalletPurchases uint64       `db:"wallet_purchases"`
+	WalletOrders    uint64       `db:"wallet_orders"`
+	MissedPurchases uint64       `db:"missed_purchases"`
+	MissedAmount    money.Amount `db:"missed_amount"`
+	MalformedRows   uint64       `db:"malformed_rows"`
+	RefundEvents    uint64       `db:"refund_events"` // только в почасовых агрегатах
+}
+
+// foldSavingsAggregates складывает строки запроса в агрегаты по пользователям
+func foldSavingsAggregates(rows []savingsAggregateRow) map[uint64]*SavingsAggregate {
+	aggs := map[uint64]*SavingsAggregate{}
+	for _, row := range rows {
+		agg := aggs[row.UserID]
+		if agg == nil {
+			agg = NewSavingsAggregate(row.UserID)
+			aggs[row.UserID] = agg
+		}
+		agg.TotalPurchases += row.TotalPurchases
+		agg.WalletPurchases += row.WalletPurchases
+		agg.WalletOrders += row.WalletOrders
+		agg.MalformedRows += row.MalformedRows
+		if row.TotalPurchases > 0 {
+			agg.CurrencyPurchases[row.Currency] += row.TotalPurchases
+		}
+		if row.MissedPurchases > 0 {
+			agg.RulePurchases[row.MissedRule] += row.MissedPurchases
+			// Date приходит без часового пояса: берём календарную дату как есть
+			day := time.Date(row.MissedDay.Year(), row.MissedDay.Month(), row.MissedDay.Day(), 0, 0, 0, 0, time.UTC)
+			agg.Missed = append(agg.Missed, MissedAmount{
+				RuleID:   row.MissedRule,
+				Currency: row.Currency,
+				Day:      day,
+				Amount:   row.MissedAmount,
+			})
+		}
+	}
+	for _, agg := range aggs {
+		agg.sortMissed()
+	}
+	return aggs
+}
+
+// cohortRow - пользователь окна страницы когорты
+type cohortRow struct {
+	UserID   uint64 `db:"user_id"`
+	InCohort bool   `db:"in_cohort"`
+}
+
+func (s *ClickHouseStore) CohortPage(ctx context.Context, cohort Cohort, afterUserID uint64, limit int) ([]uint64, uint64, error) {
+	windowArgs := []any{afterUserID, limit}
+	cond, condArgs := cohortCondition(cohort, cohortWindow, windowArgs)
+	args := append(condArgs, windowArgs...)
+	var rows []cohortRow
+	if err := s.selectContext(ctx, queryCohortPage, &rows, fmt.Sprintf(cohortPageQuery, cond, cohortWindow), args...); err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return nil, 0, nil
+	}
+	var userIDs []uint64
+	for _, row := range rows {
+		if row.InCohort {
+			userIDs = append(userIDs, row.UserID)
+		}
+	}
+	return userIDs, rows[len(rows)-1].UserID, nil
+}
+
+// cohortCondition возвращает условие когорты над группой событий пользователя и аргументы к нему.
+// users и usersArgs - условие на user_id для подзапроса отмен: отменённая или возвращённая покупка
+// кошельком, как и во всех расчётах, не считается.
+func cohortCondition(c Cohort, users string, usersArgs []any) (string, []any) {
+	conds := []string{"1"}
+	var args []any
+
+	if len(c.EventNames) > 0 {
+		eventsCond, eventsArgs := c.Events.condition()
+		conds = append(conds, fmt.Sprintf("countIf(event_name IN (%s) AND %s) > 0",
+			placeholders(len(c.EventNames)), eventsCond))
+		for _, name := range c.EventNames {
+			args = append(args, string(name))
+		}
+		args = append(args, eventsArgs...)
+	}
+	if c.NeverPaidWithWallet {
+		conds = append(conds, "countIf(event_name = 'buy' AND JSONExtractString(parameters, 'payment_method') = 'wallet' AND "+
+			fmt.Sprintf(notCancelled, users)+") = 0")
+		args = append(args, usersArgs...)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/pkg/models"
+)
+
+// Event - строка таблицы product_events
+type Event = models.Event
+
+// MemoryStore - EventStore в памяти для тестов и локальной разработки без ClickHouse.
+// Считает то же, что запросы ClickHouseStore, включая пропуск событий с невалидным JSON.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	events map[uint64][]Event // события по user_id
+	users  []uint64           // user_id по возрастанию, для страниц когорты
+}
+
+var _ EventStore = (*MemoryStore)(nil)
+
+// NewMemoryStore создаёт хранилище с заданными событиями
+func NewMemoryStore(events ...Event) *MemoryStore {
+	s := &MemoryStore{events: map[uint64][]Event{}}
+	s.Add(events...)
+	return s
+}
+
+// Add добавляет события
+func (s *MemoryStore) Add(events ...Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		if i, found := slices.BinarySearch(s.users, e.UserID); !found {
+			s.users = slices.Insert(s.users, i, e.UserID)
+		}
+		s.events[e.UserID] = append(s.events[e.UserID], e)
+	}
+}
+
+// memoryEvent - событие с разобранными параметрами покупки
+type memoryEvent struct {
+	Event
+	malformed bool
+	purchase  cashback.Purchase
+	orderID   string
+	cancelled bool // заказ покупки отменён или возвращён
+}
+
+// isBuy - покупка, которую учитывают расчёты: event_name = 'buy', валидный JSON и заказ не отменён
+func (e memoryEvent) isBuy() bool {
+	return e.EventName == models.EventBuy && !e.malformed && !e.cancelled
+}
+
+// paidWithWallet - событие buy с оплатой кошельком
+func (e memoryEvent) paidWithWallet() bool {
+	return e.EventName == models.EventBuy && e.purchase.PaymentMethod == string(models.PaymentWallet)
+}
+
+// parse разбирает parameters в нестрогом режиме, как это делают запросы через JSONExtract*
+func parse(e Event) memoryEvent {
+	params, err := models.ParseBuy(e.Parameters, models.Lenient)
+	return memoryEvent{
+		Event:     e,
+		malformed: errors.Is(err, models.ErrMalformed),
+		orderID:   params.OrderID,
+		purchase: cashback.Purchase{
+			Timestamp:     e.Timestamp,
+			Amount:        params.Amount,
+			Currency:      params.Currency,
+			PaymentMethod: string(params.PaymentMethod),
+			Category:      params.Category,
+		},
+	}
+}
+
+// userEvents возвращает разобранные события пользователя и помечает покупки отменённых заказов
+func (s *MemoryStore) userEvents(userID uint64) []memoryEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var events []memoryEvent
+	cancelled := map[string]bool{}
+	for _, e := range s.events[userID] {
+		if e.EventName.Cancels() {
+			if orderID, err := models.ParseCancelledOrder(e.EventName, e.Parameters, models.Lenient); err == nil && orderID != "" {
+				cancelled[orderID] = true
+			}
+		}
+		events = append(events, parse(e))
+	}
+	for i, e := range events {
+		events[i].cancelled = e.EventName == models.EventBuy && e.orderID != "" && cancelled[e.orderID]
+	}
+	return events
+}
+
+func (s *MemoryStore) UserStats(ctx context.Context, userID uint64) (UserStats, error) {
+	events := s.userEvents(userID)
+	stats := UserStats{UserExists: len(events) > 0}
+	for _, e := range events {
+		if e.isBuy() && e.paidWithWallet() {
+			stats.WalletOrders++
+		}
+	}
+	return stats, ctx.Err()
+}
+
+func (s *MemoryStore) Purchases(ctx context.Context, userID uint64, period TimeRange) ([]cashback.Purchase, error) {
+	var purchases []cashback.Purchase
+	for _, e := range s.userEvents(userID) {
+		if e.isBuy() && period.Contains(e.Timestamp) {
+			purchases = append(purchases, e.purchase)
+		}
+	}
+	slices.SortStableFunc(purchases, func(a, b cashback.Purchase) int { return a.Timestamp.Compare(b.Timestamp) })
+	return purchases, ctx.Err()
+}
+
+func (s *MemoryStore) SavingsAggregates(ctx context.Context, rules *cashback.RuleSet, userIDs []uint64,
+	period TimeRange) (map[uint64]*SavingsAggregate, error) {
+	aggs := make(map[uint64]*SavingsAggregate, len(userIDs))
+	for _, id := range userIDs {
+		events := s.userEvents(id)
+		if len(events) == 0 {
+			continue
+		}
+		agg := NewSavingsAggregate(id)
+		for _, e := range events {
+			if e.malformed {
+				agg.MalformedRows++
+				continue
+			}
+			if !e.isBuy() {
+				continue
+			}
+			if e.paidWithWallet() {
+				agg.WalletOrders++
+			}
+			if period.Contains(e.Timestamp) {
+				agg.AddPurchase(rules, e.purchase)
+			}
+		}
+		agg.sortMissed()
+		aggs[id] = agg
+	}
+	return aggs, ctx.Err()
+}
+
+func (s *MemoryStore) CohortPage(ctx context.Context, cohort Cohort, afterUserID uint64, limit int) ([]uint64, uint64, error) {
+	s.mu.RLock()
+	start, found := slices.BinarySearch(s.users, afterUserID)
+	if found {
+		start++
+	}
+	window := slices.Clone(s.users[start:min(start+limit, len(s.users))])
+	s.mu.RUnlock()
+	if len(window) == 0 {
+		return nil, 0, ctx.Err()
+	}
+
+	var page []uint64
+	for _, id := range window {
+		if inCohort(cohort, s.userEvents(id)) {
+			page = append(page, id)
+		}
+	}
+	return page, window[len(window)-1], ctx.Err()
+}
+
+// inCohort - аналог условия cohortCondition над событиями пользователя
+func inCohort(c Cohort, events []memoryEvent) bool {
+	if len(c.EventNames) > 0 && !slices.ContainsFunc(events, func(e memoryEvent) bool {
+		return slices.Contains(c.EventNames, e.EventName) && c.Events.Contains(e.Timestamp)
+	}) {
+		return false
+	}
+	if c.NeverPaidWithWallet && slices.ContainsFunc(events, func(e memoryEvent) bool { return e.isBuy() && e.paidWithWallet() }) {
+		return false
+	}
+	return true
+}
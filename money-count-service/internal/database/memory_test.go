@@ -0,0 +1,123 @@
+package database_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/pkg/models"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ts := func(d int) time.Time { return time.Date(2025, time.May, d, 12, 0, 0, 0, time.UTC) }
+	store := database.NewMemoryStore(
+		database.Event{Timestamp: ts(1), UserID: 1, EventName: "buy", Parameters: `{"amount":100,"payment_method":"card"}`},
+		database.Event{Timestamp: ts(2), UserID: 1, EventName: "buy", Parameters: `{"amount":"oops","payment_method":"wallet"}`},
+		database.Event{Timestamp: ts(3), UserID: 1, EventName: "buy", Parameters: `{broken`},
+		database.Event{Timestamp: ts(9), UserID: 1, EventName: "buy", Parameters: `{"amount":50,"payment_method":"card"}`},
+		database.Event{Timestamp: ts(1), UserID: 5, EventName: "open_app", Parameters: `{}`},
+		database.Event{Timestamp: ts(8), UserID: 3, EventName: "cart", Parameters: `{}`},
+	)
+	rules, err := cashback.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	ctx := context.Background()
+	period := database.TimeRange{From: ts(1), To: ts(5)}
+
+	aggs, err := store.SavingsAggregates(ctx, rules.Rules(), []uint64{1, 2}, period)
+	if err != nil {
+		t.Fatalf("SavingsAggregates: %v", err)
+	}
+	if _, ok := aggs[2]; ok {
+		t.Error("user without events must be absent")
+	}
+	agg := aggs[1]
+	if agg == nil || agg.TotalPurchases != 2 || agg.WalletPurchases != 1 || agg.WalletOrders != 1 || agg.MalformedRows != 1 {
+		t.Fatalf("aggregate = %+v", agg)
+	}
+	wantMissed := []database.MissedAmount{{RuleID: cashback.DefaultRuleID, Currency: "RUB", Day: time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC), Amount: 10000}}
+	if agg.RulePurchases[cashback.DefaultRuleID] != 1 || !slices.Equal(agg.Missed, wantMissed) {
+		t.Errorf("rule totals = %v %v", agg.RulePurchases, agg.Missed)
+	}
+	if len(agg.CurrencyPurchases) != 1 || agg.CurrencyPurchases["RUB"] != 2 {
+		t.Errorf("currency purchases = %v", agg.CurrencyPurchases)
+	}
+
+	purchases, err := store.Purchases(ctx, 1, database.TimeRange{})
+	if err != nil || len(purchases) != 3 || purchases[1].Amount != 0 {
+		t.Errorf("Purchases = %v, %v", purchases, err)
+	}
+
+	tests := []struct {
+		name     string
+		cohort   database.Cohort
+		after    uint64
+		limit    int
+		want     []uint64
+		wantLast uint64
+	}{
+		{"all", database.Cohort{}, 0, 10, []uint64{1, 3, 5}, 5},
+		{"after and limit", database.Cohort{}, 1, 1, []uint64{3}, 3},
+		{"after missing user", database.Cohort{}, 2, 10, []uint64{3, 5}, 5},
+		{"never wallet", database.Cohort{NeverPaidWithWallet: true}, 0, 10, []uint64{3, 5}, 5},
+		{"page without cohort users", database.Cohort{NeverPaidWithWallet: true}, 0, 1, nil, 1},
+		{"event in window", database.Cohort{EventNames: []models.EventName{models.EventOpenApp, models.EventCart}, Events: database.TimeRange{From: ts(5)}}, 0, 10, []uint64{3}, 5},
+		{"no more users", database.Cohort{}, 5, 10, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, last, err := store.CohortPage(ctx, tt.cohort, tt.after, tt.limit)
+			if err != nil || !slices.Equal(got, tt.want) || last != tt.wantLast {
+				t.Errorf("CohortPage = %v, %d, %v; want %v, %d", got, last, err, tt.want, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreCancelledOrders(t *testing.T) {
+	ts := func(d int) time.Time { return time.Date(2025, time.May, d, 12, 0, 0, 0, time.UTC) }
+	store := database.NewMemoryStore(
+		database.Event{Timestamp: ts(1), UserID: 7, EventName: "buy", Parameters: `{"amount":200,"payment_method":"card","order_id":"7-1"}`},
+		database.Event{Timestamp: ts(2), UserID: 7, EventName: "buy", Parameters: `{"amount":300,"payment_method":"wallet","order_id":"7-2"}`},
+		database.Event{Timestamp: ts(3), UserID: 7, EventName: "buy", Parameters: `{"amount":400,"payment_method":"card","order_id":"7-3"}`},
+		database.Event{Timestamp: ts(2), UserID: 7, EventName: "cancel", Parameters: `{"order_id":"7-2"}`},
+		// возврат после конца периода всё равно убирает покупку из него
+		database.Event{Timestamp: ts(20), UserID: 7, EventName: "refund", Parameters: `{"order_id":"7-1","reason":"defect"}`},
+		// заказ с тем же номером у другого пользователя не трогает покупки пользователя 7
+		database.Event{Timestamp: ts(3), UserID: 8, EventName: "refund", Parameters: `{"order_id":"7-3"}`},
+	)
+	rules, err := cashback.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	ctx := context.Background()
+
+	aggs, err := store.SavingsAggregates(ctx, rules.Rules(), []uint64{7}, database.TimeRange{From: ts(1), To: ts(5)})
+	if err != nil {
+		t.Fatalf("SavingsAggregates: %v", err)
+	}
+	agg := aggs[7]
+	if agg == nil || agg.TotalPurchases != 1 || agg.WalletPurchases != 0 || agg.WalletOrders != 0 ||
+		agg.RulePurchases[cashback.DefaultRuleID] != 1 || agg.Missed[0].Amount != 40000 {
+		t.Fatalf("aggregate = %+v", agg)
+	}
+
+	stats, err := store.UserStats(ctx, 7)
+	if err != nil || !stats.UserExists || stats.WalletOrders != 0 {
+		t.Errorf("UserStats = %+v, %v", stats, err)
+	}
+	purchases, err := store.Purchases(ctx, 7, database.TimeRange{})
+	if err != nil || len(purchases) != 1 || purchases[0].Amount != 40000 {
+		t.Errorf("Purchases = %v, %v", purchases, err)
+	}
+
+	// отменённая покупка кошельком не выводит пользователя 7 из когорты
+	users, _, err := store.CohortPage(ctx, database.Cohort{NeverPaidWithWallet: true}, 0, 10)
+	if err != nil || !slices.Equal(users, []uint64{7, 8}) {
+		t.Errorf("CohortPage(never wallet) = %v, %v; want [7 8]", users, err)
+	}
+}
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// migrationsTable - учёт применённых миграций. ClickHouse не умеет транзакций и обычного DELETE,
+// поэтому откат записывается новой строкой, а ReplacingMergeTree оставляет последнюю по changed_at.
+const migrationsTable = "schema_migrations"
+
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS ` + migrationsTable + `
+	(
+		version    UInt32,
+		name       String,
+		applied    UInt8,
+		changed_at DateTime64(6)
+	)
+	ENGINE = ReplacingMergeTree(changed_at)
+	ORDER BY version`
+
+// cutoffPlaceholder заменяется в запросах миграции моментом её запуска, одним на все запросы.
+// Так представление и разовый перенос накопленных событий делят события без пересечения.
+const cutoffPlaceholder = "{cutoff}"
+
+// migrationSettings - настройки запросов миграций. ALTER TABLE ... MODIFY QUERY меняет запрос
+// материализованного представления без пересоздания, но ClickHouse до 24.x требует для этого флаг.
+var migrationSettings = clickhouse.Settings{"allow_experimental_alter_materialized_view_structure": 1}
+
+// migrationFile - имя файла миграции: 0001_product_events.up.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration - версия схемы: запросы применения и отката
+type Migration struct {
+	Version uint32
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// MigrationStatus - миграция и применена ли она
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// Migrations возвращает миграции, встроенные в бинарник, по возрастанию версии
+func Migrations() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(sub)
+}
+
+// LoadMigrations читает пары файлов NNNN_name.up.sql и NNNN_name.down.sql из корня fsys.
+// Запросы в файле разделяются точкой с запятой, строки-комментарии "--" пропускаются:
+// ClickHouse выполняет только один запрос за вызов. {cutoff} в запросе - момент запуска миграции.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("чтение миграций: %w", err)
+	}
+
+	byVersion := map[uint32]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("миграция %s: ожидается имя вида 0001_name.up.sql", e.Name())
+		}
+		version, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("миграция %s: некорректная версия", e.Name())
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("миграция %s: %w", e.Name(), err)
+		}
+
+		mig, ok := byVersion[uint32(version)]
+		if !ok {
+			mig = &Migration{Version: uint32(version), Name: m[2]}
+			byVersion[mig.Version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("миграция %d: разные имена %s и %s", version, mig.Name, m[2])
+		}
+		statements := splitStatements(string(data))
+		if len(statements) == 0 {
+			return nil, fmt.Errorf("миграция %s: нет запросов", e.Name())
+		}
+		if m[3] == "up" {
+			mig.Up = statements
+		} else {
+			mig.Down = statements
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == nil || mig.Down == nil {
+			return nil, fmt.Errorf("миграция %04d_%s: нужны оба файла, up и down", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return int(a.Version) - int(b.Version) })
+	return migrations, nil
+}
+
+// splitStatements делит файл на запросы по точке с запятой, выбрасывая комментарии и пустые строки
+func splitStatements(sql string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	var statements []string
+	for _, s := range strings.Split(b.String(), ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			statements = append(statements, s)
+		}
+	}
+	return statements
+}
+
+// Migrator применяет и откатывает миграции, отмечая их в schema_migrations.
+// Запросы миграций должны быть идемпотентными (IF NOT EXISTS / IF EXISTS): если миграция
+// упала посередине, её можно просто запустить ещё раз.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// NewMigrator создаёт мигратор для заданного набора миграций, обычно Migrations()
+func NewMigrator(db *sqlx.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Status возвращает все известные миграции с отметкой о применении
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = MigrationStatus{Migration: mig, Applied: applied[mig.Version]}
+	}
+	return statuses, nil
+}
+
+// Up применяет все ещё не применённые миграции по возрастанию версии и возвращает их
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range m.migrations {
+		if applied[mig.Version] {
+			continue
+		}
+		if err := m.run(ctx, mig, mig.Up, true); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down откатывает steps последних применённых миграций и возвращает их
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+		if !applied[mig.Version] {
+			continue
+		}
+		if err := m.run(ctx, mig, mig.Down, false); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// run выполняет запросы миграции и записывает её новое состояние
+func (m *Migrator) run(ctx context.Context, mig Migration, statements []string, applied bool) error {
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(migrationSettings))
+	cutoff := fmt.Sprintf("toDateTime('%s', 'UTC')", time.Now().UTC().Format(time.DateTime))
+	for i, stmt := range statements {
+		stmt = strings.ReplaceAll(stmt, cutoffPlaceholder, cutoff)
+		if _, err := m.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("миграция %04d_%s, запрос %d: %w", mig.Version, mig.Name, i+1, err)
+		}
+	}
+	_, err := m.db.ExecContext(ctx,
+		"INSERT INTO "+migrationsTable+" (version, name, applied, changed_at) VALUES (?, ?, ?, now64(6))",
+		mig.Version, mig.Name, boolToUInt8(applied))
+	if err != nil {
+		return fmt.Errorf("запись миграции %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+// applied создаёт таблицу учёта при первом запуске и возвращает применённые версии
+func (m *Migrator) applied(ctx context.Context) (map[uint32]bool, error) {
+	if _, err := m.db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("создание %s: %w", migrationsTable, err)
+	}
+	var versions []uint32
+	err := m.db.SelectContext(ctx, &versions,
+		"SELECT version FROM "+migrationsTable+" GROUP BY version HAVING argMax(applied, changed_at) = 1")
+	if err != nil {
+		return nil, fmt.Errorf("чтение %s: %w", migrationsTable, err)
+	}
+	applied := make(map[uint32]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+func boolToUInt8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package database_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Qwental/wb-money/internal/database"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := database.Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Name != "product_events" {
+		t.Fatalf("first migration = %+v, want product_events", migrations)
+	}
+	if up := migrations[0].Up[0]; !strings.Contains(up, "ORDER BY (user_id, timestamp)") {
+		t.Errorf("product_events DDL has unexpected sort key:\n%s", up)
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("migrations not sorted: %d after %d", migrations[i].Version, migrations[i-1].Version)
+		}
+		// Представление и перенос накопленных событий делят события по моменту запуска миграции
+		if migrations[i].Name == "user_purchases_hourly" {
+			up := strings.Join(migrations[i].Up, "\n")
+			if !strings.Contains(up, "timestamp >= {cutoff}") || !strings.Contains(up, "timestamp < {cutoff}") {
+				t.Errorf("view and backfill of %s are not split by {cutoff}:\n%s", migrations[i].Name, up)
+			}
+		}
+		// Пересоздание представления теряет вставки между DROP и CREATE: его запрос меняют на месте
+		for _, stmt := range slices.Concat(migrations[i].Up, migrations[i].Down) {
+			if strings.HasPrefix(stmt, "DROP VIEW") && migrations[i].Name != "user_purchases_hourly" {
+				t.Errorf("migration %04d_%s recreates a view:\n%s", migrations[i].Version, migrations[i].Name, stmt)
+			}
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+	t.Run("sorted and split", func(t *testing.T) {
+		migrations, err := database.LoadMigrations(fstest.MapFS{
+			"0002_view.up.sql":     file("-- view; with comment\nCREATE TABLE a (x UInt8) ENGINE = Memory;\nCREATE VIEW v AS SELECT x FROM a;\n"),
+			"0002_view.down.sql":   file("DROP VIEW v;\nDROP TABLE a;"),
+			"0001_events.up.sql":   file("CREATE TABLE e (x UInt8) ENGINE = Memory"),
+			"0001_events.down.sql": file("DROP TABLE e"),
+			"README.md":            file("ignored"),
+		})
+		if err != nil {
+			t.Fatalf("LoadMigrations: %v", err)
+		}
+		if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+			t.Fatalf("migrations = %+v", migrations)
+		}
+		if got := migrations[1].Up; len(got) != 2 || got[1] != "CREATE VIEW v AS SELECT x FROM a" {
+			t.Errorf("statements = %q", got)
+		}
+	})
+
+	errorCases := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr string
+	}{
+		{"missing down", fstest.MapFS{"0001_e.up.sql": file("SELECT 1")}, "up и down"},
+		{"bad name", fstest.MapFS{"events.up.sql": file("SELECT 1")}, "0001_name"},
+		{"zero version", fstest.MapFS{"0000_e.up.sql": file("SELECT 1"), "0000_e.down.sql": file("SELECT 1")}, "версия"},
+		{"name mismatch", fstest.MapFS{"0001_a.up.sql": file("SELECT 1"), "0001_b.down.sql": file("SELECT 1")}, "разные имена"},
+		{"empty file", fstest.MapFS{"0001_e.up.sql": file("-- nothing\n"), "0001_e.down.sql": file("SELECT 1")}, "нет запросов"},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := database.LoadMigrations(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want mention of %q", err, tt.wantErr)
+			}
+		})
+	}
+}
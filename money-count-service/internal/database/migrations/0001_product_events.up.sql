@@ -0,0 +1,12 @@
+-- События приложения: open_app, cart, payment_methods, buy.
+-- Все запросы сервиса фильтруют по user_id и периоду, отсюда ключ сортировки.
+CREATE TABLE IF NOT EXISTS product_events
+(
+    timestamp  DateTime,
+    user_id    UInt64,
+    event_name LowCardinality(String),
+    parameters String
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(timestamp)
+ORDER BY (user_id, timestamp);
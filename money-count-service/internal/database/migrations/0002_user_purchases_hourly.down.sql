@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS user_purchases_hourly_mv;
+DROP TABLE IF EXISTS user_purchases_hourly;
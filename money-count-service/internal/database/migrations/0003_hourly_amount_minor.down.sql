@@ -0,0 +1,13 @@
+ALTER TABLE user_purchases_hourly_mv MODIFY QUERY
+SELECT
+    user_id,
+    toStartOfHour(timestamp) AS hour,
+    if(event_name = 'buy' AND isValidJSON(parameters), JSONExtractString(parameters, 'payment_method'), '') AS payment_method,
+    if(event_name = 'buy' AND isValidJSON(parameters), JSONExtractString(parameters, 'category'), '') AS category,
+    count() AS events,
+    countIf(event_name = 'buy' AND isValidJSON(parameters)) AS purchases,
+    sumIf(JSONExtractFloat(parameters, 'amount'), event_name = 'buy' AND isValidJSON(parameters)) AS amount
+FROM product_events
+GROUP BY user_id, hour, payment_method, category;
+
+ALTER TABLE user_purchases_hourly DROP COLUMN IF EXISTS amount_minor;
@@ -0,0 +1,19 @@
+-- Суммы почасовых агрегатов в копейках: сложение Float64 копит ошибку округления.
+-- Для уже накопленных строк копейки вычисляются из amount, новые строки пишет представление.
+ALTER TABLE user_purchases_hourly
+    ADD COLUMN IF NOT EXISTS amount_minor Int64 DEFAULT toInt64(round(amount * 100)) AFTER amount;
+
+-- Запрос представления меняется на месте, без пересоздания: вставки не прерываются,
+-- и ни одно событие не проходит мимо агрегатов.
+ALTER TABLE user_purchases_hourly_mv MODIFY QUERY
+SELECT
+    user_id,
+    toStartOfHour(timestamp) AS hour,
+    if(event_name = 'buy' AND isValidJSON(parameters), JSONExtractString(parameters, 'payment_method'), '') AS payment_method,
+    if(event_name = 'buy' AND isValidJSON(parameters), JSONExtractString(parameters, 'category'), '') AS category,
+    count() AS events,
+    countIf(event_name = 'buy' AND isValidJSON(parameters)) AS purchases,
+    sumIf(JSONExtractFloat(parameters, 'amount'), event_name = 'buy' AND isValidJSON(parameters)) AS amount,
+    sumIf(toInt64(round(JSONExtractFloat(parameters, 'amount') * 100)), event_name = 'buy' AND isValidJSON(parameters)) AS amount_minor
+FROM product_events
+GROUP BY user_id, hour, payment_method, category;
@@ -0,0 +1,16 @@
+ALTER TABLE user_purchases_hourly_mv MODIFY QUERY
+SELECT
+    user_id,
+    toStartOfHour(timestamp) AS hour,
+    if(event_name = 'buy' AND isValidJSON(parameters), JSONExtractString(parameters, 'payment_method'), '') AS payment_method,
+    if(event_name = 'buy' AND isValidJSON(parameters), JSONExtractString(parameters, 'category'), '') AS category,
+    count() AS events,
+    countIf(event_name = 'buy' AND isValidJSON(parameters)) AS purchases,
+    sumIf(JSONExtractFloat(parameters, 'amount'), event_name = 'buy' AND isValidJSON(parameters)) AS amount,
+    sumIf(toInt64(round(JSONExtractFloat(parameters, 'amount') * 100)), event_name = 'buy' AND isValidJSON(parameters)) AS amount_minor
+FROM product_events
+GROUP BY user_id, hour, payment_method, category;
+
+-- Колонку currency удалить нельзя, пока она в ключе сортировки: она остаётся,
+-- новые строки получают пустую валюту, которая и так читается как RUB.
+DROP TABLE IF EXISTS exchange_rates;
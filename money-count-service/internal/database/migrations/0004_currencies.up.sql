@@ -0,0 +1,31 @@
+-- Курсы валют к рублю по датам: курс действует с date до следующей даты этой валюты.
+-- Повторная вставка на ту же дату заменяет курс после слияния частей, поэтому читать с FINAL.
+CREATE TABLE IF NOT EXISTS exchange_rates
+(
+    date     Date,
+    currency LowCardinality(String),
+    rate     Decimal(18, 8)
+)
+ENGINE = ReplacingMergeTree
+ORDER BY (currency, date);
+
+-- Валюта покупки в почасовых агрегатах. Колонка входит в ключ сортировки, а его можно расширить
+-- только колонкой без значения по умолчанию: у накопленных строк валюта пустая и читается как RUB.
+ALTER TABLE user_purchases_hourly
+    ADD COLUMN IF NOT EXISTS currency LowCardinality(String) AFTER category,
+    MODIFY ORDER BY (user_id, hour, payment_method, category, currency);
+
+-- Запрос представления меняется на месте, не прерывая вставки.
+ALTER TABLE user_purchases_hourly_mv MODIFY QUERY
+SELECT
+    user_id,
+    toStartOfHour(timestamp) AS hour,
+    if(event_name = 'buy' AND isValidJSON(parameters), JSONExtractString(parameters, 'payment_method'), '') AS payment_method,
+    if(event_name = 'buy' AND isValidJSON(parameters), JSONExtractString(parameters, 'category'), '') AS category,
+    if(event_name = 'buy' AND isValidJSON(parameters), coalesce(nullIf(JSONExtractString(parameters, 'currency'), ''), 'RUB'), '') AS currency,
+    count() AS events,
+    countIf(event_name = 'buy' AND isValidJSON(parameters)) AS purchases,
+    sumIf(JSONExtractFloat(parameters, 'amount'), event_name = 'buy' AND isValidJSON(parameters)) AS amount,
+    sumIf(toInt64(round(JSONExtractFloat(parameters, 'amount') * 100)), event_name = 'buy' AND isValidJSON(parameters)) AS amount_minor
+FROM product_events
+GROUP BY user_id, hour, payment_method, category, currency;
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/internal/metrics"
+	"github.com/Qwental/wb-money/internal/tracing"
+)
+
+// DefaultQueryTimeout - предельное время одного запроса к ClickHouse, если не задано иное
+const DefaultQueryTimeout = 30 * time.Second
+
+// clickhouseTimeoutExceeded - код исключения TIMEOUT_EXCEEDED, когда сработал max_execution_time
+const clickhouseTimeoutExceeded = 159
+
+// IsTimeout сообщает, что запрос прерван по времени, а не упал: истёк дедлайн контекста
+// или ClickHouse сам остановил его по max_execution_time
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ex *clickhouse.Exception
+	return errors.As(err, &ex) && ex.Code == clickhouseTimeoutExceeded
+}
+
+// withQueryTimeout ограничивает запрос временем queryTimeout или остатком дедлайна вызова, если он меньше.
+// То же время передаётся ClickHouse в max_execution_time, чтобы сервер сам прекратил тяжёлый запрос,
+// а не продолжал считать после отмены на стороне клиента.
+func (s *ClickHouseStore) withQueryTimeout(ctx context.Context, query string) (context.Context, context.CancelFunc, string) {
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	deadline, _ := ctx.Deadline()
+	seconds := max(1, int(math.Ceil(time.Until(deadline).Seconds())))
+	return ctx, cancel, fmt.Sprintf("%s\n\tSETTINGS max_execution_time = %d, timeout_overflow_mode = 'throw'", query, seconds)
+}
+
+// selectContext выполняет запрос, возвращающий много строк, в отдельном спане
+// и записывает его метрики под именем name. При сетевой ошибке запрос повторяется
+// на новом соединении, возможно к другой реплике, пока не истечёт его время.
+func (s *ClickHouseStore) selectContext(ctx context.Context, name string, dest any, query string, args ...any) error {
+	ctx, cancel, query := s.withQueryTimeout(ctx, query)
+	defer cancel()
+	return s.retry.Do(ctx, func() error {
+		// sqlx дописывает строки в срез; при повторе начинаем с пустого
+		reflect.ValueOf(dest).Elem().SetZero()
+		return s.observeQuery(ctx, name, query, func(ctx context.Context) error {
+			return s.db.SelectContext(ctx, dest, query, args...)
+		})
+	}, func(err error) {
+		logging.FromContext(ctx).Warn("retrying query", slog.String("query", name), slog.Any("error", err))
+	})
+}
+
+// getContext - то же для запроса ровно одной строки
+func (s *ClickHouseStore) getContext(ctx context.Context, name string, dest any, query string, args ...any) error {
+	ctx, cancel, query := s.withQueryTimeout(ctx, query)
+	defer cancel()
+	return s.retry.Do(ctx, func() error {
+		return s.observeQuery(ctx, name, query, func(ctx context.Context) error {
+			return s.db.GetContext(ctx, dest, query, args...)
+		})
+	}, func(err error) {
+		logging.FromContext(ctx).Warn("retrying query", slog.String("query", name), slog.Any("error", err))
+	})
+}
+
+// observeQuery выполняет одну попытку запроса в отдельном спане и записывает её метрики
+func (s *ClickHouseStore) observeQuery(ctx context.Context, name, query string, run func(context.Context) error) error {
+	ctx, span := tracing.StartQuery(ctx, name, query)
+	start := time.Now()
+	err := run(ctx)
+	metrics.ObserveQuery(name, start, err)
+	tracing.EndQuery(span, err)
+	return err
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/exchange"
+	"github.com/shopspring/decimal"
+)
+
+// queryExchangeRates - имя запроса курсов валют в метриках и трейсах
+const queryExchangeRates = "exchange_rates"
+
+// exchangeRatesQuery читает все курсы. Decimal отдаётся строкой: database/sql не умеет
+// сканировать его в decimal.Decimal.
+const exchangeRatesQuery = `
+	SELECT date, currency, toString(rate) AS rate
+	FROM exchange_rates FINAL
+	ORDER BY currency, date
+`
+
+type exchangeRateRow struct {
+	Date     time.Time `db:"date"`
+	Currency string    `db:"currency"`
+	Rate     string    `db:"rate"`
+}
+
+// ExchangeRates читает таблицу exchange_rates для exchange.NewCached
+func (s *ClickHouseStore) ExchangeRates(ctx context.Context) ([]exchange.Rate, error) {
+	var rows []exchangeRateRow
+	if err := s.selectContext(ctx, queryExchangeRates, &rows, exchangeRatesQuery); err != nil {
+		return nil, err
+	}
+	rates := make([]exchange.Rate, len(rows))
+	for i, row := range rows {
+		rate, err := decimal.NewFromString(row.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("курс %s на %s: %w", row.Currency, row.Date.Format(time.DateOnly), err)
+		}
+		rates[i] = exchange.Rate{
+			Day:      time.Date(row.Date.Year(), row.Date.Month(), row.Date.Day(), 0, 0, 0, 0, time.UTC),
+			Currency: row.Currency,
+			Rate:     rate,
+		}
+	}
+	return rates, nil
+}
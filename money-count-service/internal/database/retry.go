@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Retry - ограниченные повторы с экспоненциальной задержкой для временных сетевых ошибок
+type Retry struct {
+	Attempts       int           // всего попыток, включая первую; 0 или 1 - без повторов
+	InitialBackoff time.Duration // задержка перед первым повтором, дальше удваивается
+	MaxBackoff     time.Duration // предел задержки
+}
+
+// Do выполняет fn, повторяя её при временных ошибках, пока не кончатся попытки или контекст.
+// onRetry вызывается перед каждым повтором; может быть nil.
+func (r Retry) Do(ctx context.Context, fn func() error, onRetry func(err error)) error {
+	backoff := r.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.Attempts || !IsTransient(err) {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, r.MaxBackoff)
+	}
+}
+
+// IsTransient сообщает, что ошибка сетевая и запрос имеет смысл повторить, возможно
+// на другой реплике: соединение отклонено, разорвано или не установлено вовремя.
+// Ошибки самого запроса, авторизации и отмена контекста временными не считаются.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
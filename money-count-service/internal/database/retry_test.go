@@ -0,0 +1,90 @@
+package database_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/database"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{"wrapped reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"bad conn", driver.ErrBadConn, true},
+		{"dns", &net.DNSError{Err: "no such host", Name: "clickhouse"}, true},
+		{"deadline", context.DeadlineExceeded, false},
+		{"canceled", fmt.Errorf("query: %w", context.Canceled), false},
+		{"query error", errors.New("code: 62, message: Syntax error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := database.IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDo(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	syntax := errors.New("syntax error")
+	retry := database.Retry{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	tests := []struct {
+		name      string
+		errs      []error // ошибки по попыткам; дальше - успех
+		wantCalls int
+		wantErr   error
+	}{
+		{"success first", nil, 1, nil},
+		{"recovers after refused", []error{refused, refused}, 3, nil},
+		{"gives up after attempts", []error{refused, refused, refused, refused}, 3, syscall.ECONNREFUSED},
+		{"no retry on query error", []error{syntax}, 1, syntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, retries := 0, 0
+			err := retry.Do(context.Background(), func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			}, func(error) { retries++ })
+
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if retries != calls-1 {
+				t.Errorf("onRetry called %d times for %d calls", retries, calls)
+			}
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryDoStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	retry := database.Retry{Attempts: 100, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	err := retry.Do(ctx, func() error {
+		return syscall.ECONNREFUSED
+	}, func(error) { cancel() })
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("err = %v, want canceled wrapping the last error", err)
+	}
+}
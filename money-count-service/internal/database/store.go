@@ -0,0 +1,136 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/exchange"
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/money"
+)
+
+// EventStore - чтение событий product_events, из которых MoneyService считает экономию.
+// ClickHouseStore считает всё в базе, MemoryStore - в памяти для тестов без ClickHouse;
+// для одних и тех же событий они возвращают одинаковые результаты.
+type EventStore interface {
+	// UserStats сообщает, есть ли у пользователя события, и сколько раз за всё время он платил кошельком
+	UserStats(ctx context.Context, userID uint64) (UserStats, error)
+	// Purchases возвращает покупки пользователя за период по возрастанию времени.
+	// События с невалидным JSON в parameters пропускаются.
+	Purchases(ctx context.Context, userID uint64, period TimeRange) ([]cashback.Purchase, error)
+	// SavingsAggregates считает агрегаты для расчёта экономии сразу по многим пользователям.
+	// Пользователей без единого события в результате нет.
+	SavingsAggregates(ctx context.Context, rules *cashback.RuleSet, userIDs []uint64, period TimeRange) (map[uint64]*SavingsAggregate, error)
+	// CohortPage просматривает следующих limit пользователей с user_id больше afterUserID по возрастанию
+	// и возвращает тех из них, кто входит в когорту, и последний просмотренный user_id - с него
+	// начинается следующая страница. Последний user_id 0 означает, что пользователей больше нет.
+	CohortPage(ctx context.Context, cohort Cohort, afterUserID uint64, limit int) (userIDs []uint64, last uint64, err error)
+}
+
+// TimeRange - полуинтервал [From, To) по времени события.
+// Нулевая граница означает, что с этой стороны период не ограничен.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// IsZero сообщает, что период не ограничен ни с одной стороны
+func (r TimeRange) IsZero() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+// Contains сообщает, что момент t попадает в период
+func (r TimeRange) Contains(t time.Time) bool {
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || t.Before(r.To))
+}
+
+// condition возвращает SQL-условие по timestamp и аргументы к нему
+func (r TimeRange) condition() (string, []any) {
+	cond := "1"
+	var args []any
+	if !r.From.IsZero() {
+		cond += " AND timestamp >= ?"
+		args = append(args, r.From)
+	}
+	if !r.To.IsZero() {
+		cond += " AND timestamp < ?"
+		args = append(args, r.To)
+	}
+	return cond, args
+}
+
+// Cohort - фильтр пользователей для выгрузки экономии
+type Cohort struct {
+	EventNames          []models.EventName // было хотя бы одно из этих событий внутри Events
+	Events              TimeRange          // учитывается только вместе с EventNames
+	NeverPaidWithWallet bool
+}
+
+// UserStats - существование пользователя и его покупки кошельком за всё время
+type UserStats struct {
+	UserExists   bool   `db:"user_exists"`
+	WalletOrders uint64 `db:"wallet_orders"`
+}
+
+// SavingsAggregate - всё необходимое для GetSavingsResponse одного пользователя
+type SavingsAggregate struct {
+	UserID            uint64
+	TotalPurchases    uint64
+	WalletPurchases   uint64
+	WalletOrders      uint64 // за всё время, для уровня кэшбека
+	RulePurchases     map[string]uint64
+	CurrencyPurchases map[string]uint64 // покупки за период по валютам
+	Missed            []MissedAmount    // упущенные покупки по правилам, валютам и дням
+	MalformedRows     uint64            // события с невалидным JSON, пропущенные при подсчёте
+}
+
+// MissedAmount - сумма упущенных покупок одного правила в одной валюте за сутки (UTC).
+// По дню выбирается курс, если экономию нужно показать в другой валюте.
+type MissedAmount struct {
+	RuleID   string
+	Currency string
+	Day      time.Time
+	Amount   money.Amount
+}
+
+// NewSavingsAggregate создаёт пустой агрегат пользователя
+func NewSavingsAggregate(userID uint64) *SavingsAggregate {
+	return &SavingsAggregate{UserID: userID, RulePurchases: map[string]uint64{}, CurrencyPurchases: map[string]uint64{}}
+}
+
+// AddPurchase учитывает покупку из периода так же, как запросы агрегатов: в общем числе, по валюте
+// и среди оплаченных кошельком, а упущенную - в сумме первого подошедшего правила за её день (UTC).
+// WalletOrders считается за всё время и здесь не меняется.
+func (a *SavingsAggregate) AddPurchase(rules *cashback.RuleSet, p cashback.Purchase) {
+	a.TotalPurchases++
+	a.CurrencyPurchases[p.Currency]++
+	if p.PaymentMethod == string(models.PaymentWallet) {
+		a.WalletPurchases++
+		return
+	}
+	if rule, ok := rules.Match(p); ok {
+		a.RulePurchases[rule.ID]++
+		a.addMissed(MissedAmount{RuleID: rule.ID, Currency: p.Currency, Day: exchange.Day(p.Timestamp), Amount: p.Amount})
+	}
+}
+
+// addMissed прибавляет упущенную покупку к сумме её правила, валюты и дня
+func (a *SavingsAggregate) addMissed(m MissedAmount) {
+	for i := range a.Missed {
+		if a.Missed[i].RuleID == m.RuleID && a.Missed[i].Currency == m.Currency && a.Missed[i].Day.Equal(m.Day) {
+			a.Missed[i].Amount += m.Amount
+			return
+		}
+	}
+	a.Missed = append(a.Missed, m)
+}
+
+// sortMissed упорядочивает суммы по правилу, валюте и дню, чтобы хранилища возвращали одно и то же
+func (a *SavingsAggregate) sortMissed() {
+	slices.SortFunc(a.Missed, func(x, y MissedAmount) int {
+		return cmp.Or(cmp.Compare(x.RuleID, y.RuleID), cmp.Compare(x.Currency, y.Currency), x.Day.Compare(y.Day))
+	})
+}
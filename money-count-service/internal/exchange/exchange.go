@@ -0,0 +1,224 @@
+// Package exchange переводит суммы между валютами по курсам на дату покупки
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// Base - базовая валюта: курсы задаются в рублях за единицу валюты
+const Base = models.DefaultCurrency
+
+var (
+	ErrNoRate      = errors.New("нет курса валюты")
+	ErrInvalidRate = errors.New("курс должен быть больше нуля")
+)
+
+// Provider - источник курсов валют к Base
+type Provider interface {
+	// Rate возвращает, сколько единиц Base стоит одна единица currency на дату day.
+	// Если курса на эту дату нет, действует последний известный до неё.
+	Rate(ctx context.Context, currency string, day time.Time) (decimal.Decimal, error)
+}
+
+// Rate - курс валюты к Base, действующий с даты Day
+type Rate struct {
+	Day      time.Time
+	Currency string
+	Rate     decimal.Decimal
+}
+
+// Table - неизменяемая таблица курсов; сама является Provider
+type Table struct {
+	rates map[string][]Rate // по возрастанию Day
+}
+
+var _ Provider = (*Table)(nil)
+
+// NewTable проверяет курсы и строит по ним таблицу. Повторный курс на ту же дату заменяет прежний.
+func NewTable(rates []Rate) (*Table, error) {
+	t := &Table{rates: map[string][]Rate{}}
+	var errs []error
+	for _, r := range rates {
+		if !models.ValidCurrency(r.Currency) {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Currency, models.ErrInvalidCurrency))
+			continue
+		}
+		if !r.Rate.IsPositive() {
+			errs = append(errs, fmt.Errorf("%s на %s = %s: %w", r.Currency, r.Day.Format(time.DateOnly), r.Rate, ErrInvalidRate))
+			continue
+		}
+		r.Day = Day(r.Day)
+		t.rates[r.Currency] = append(t.rates[r.Currency], r)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	for currency, list := range t.rates {
+		slices.SortStableFunc(list, func(a, b Rate) int { return a.Day.Compare(b.Day) })
+		// Из повторов на одну дату оставляем последний
+		uniq := list[:0]
+		for _, r := range list {
+			if n := len(uniq); n > 0 && uniq[n-1].Day.Equal(r.Day) {
+				uniq[n-1] = r
+				continue
+			}
+			uniq = append(uniq, r)
+		}
+		t.rates[currency] = uniq
+	}
+	return t, nil
+}
+
+// Day приводит момент к началу суток по UTC: курсы действуют на календарную дату
+func Day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Rate ищет последний курс currency на дату day или раньше
+func (t *Table) Rate(_ context.Context, currency string, day time.Time) (decimal.Decimal, error) {
+	if currency == Base {
+		return decimal.NewFromInt(1), nil
+	}
+	list := t.rates[currency]
+	day = Day(day)
+	i, found := slices.BinarySearchFunc(list, day, func(r Rate, d time.Time) int { return r.Day.Compare(d) })
+	if !found {
+		i--
+	}
+	if i < 0 {
+		return decimal.Decimal{}, fmt.Errorf("%s на %s: %w", currency, day.Format(time.DateOnly), ErrNoRate)
+	}
+	return list[i].Rate, nil
+}
+
+// retryBackoff - пауза перед повтором загрузки курсов после ошибки. С каждой неудачей подряд
+// удваивается, но не дольше refresh.
+const retryBackoff = time.Second
+
+// Cached - Provider, который загружает таблицу курсов целиком и перечитывает её раз в refresh.
+// Если перечитать не удалось, продолжает действовать прежняя таблица, а следующая попытка
+// будет не раньше паузы: запросы не выстраиваются в очередь за недоступной базой.
+type Cached struct {
+	load    func(ctx context.Context) ([]Rate, error)
+	refresh time.Duration
+
+	mu       sync.Mutex
+	table    *Table
+	loadedAt time.Time
+	failures int       // неудачных загрузок подряд
+	retryAt  time.Time // до этого момента после неудачи не перечитываем
+	lastErr  error     // ошибка последней загрузки, если таблицы ещё нет
+}
+
+var _ Provider = (*Cached)(nil)
+
+// NewCached создаёт провайдер поверх функции загрузки; таблица загружается при первом запросе курса
+func NewCached(load func(ctx context.Context) ([]Rate, error), refresh time.Duration) *Cached {
+	return &Cached{load: load, refresh: refresh}
+}
+
+func (c *Cached) Rate(ctx context.Context, currency string, day time.Time) (decimal.Decimal, error) {
+	if currency == Base {
+		return decimal.NewFromInt(1), nil
+	}
+	table, err := c.current(ctx)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return table.Rate(ctx, currency, day)
+}
+
+func (c *Cached) current(ctx context.Context) (*Table, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.table != nil && time.Since(c.loadedAt) < c.refresh {
+		return c.table, nil
+	}
+	if c.failures > 0 && time.Now().Before(c.retryAt) {
+		return c.stale(c.lastErr)
+	}
+	table, err := c.reload(ctx)
+	if err != nil {
+		// Отмена запроса клиентом не говорит о недоступности источника
+		if ctx.Err() == nil {
+			c.failures++
+			c.retryAt = time.Now().Add(c.backoff())
+			c.lastErr = err
+		}
+		return c.stale(err)
+	}
+	c.table, c.loadedAt, c.failures, c.lastErr = table, time.Now(), 0, nil
+	return table, nil
+}
+
+// stale возвращает прежнюю таблицу, а если её нет - ошибку загрузки
+func (c *Cached) stale(err error) (*Table, error) {
+	if c.table != nil {
+		return c.table, nil
+	}
+	return nil, err
+}
+
+// backoff - пауза после очередной неудачи подряд
+func (c *Cached) backoff() time.Duration {
+	limit := max(c.refresh, retryBackoff)
+	d := retryBackoff
+	for i := 1; i < c.failures && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+func (c *Cached) reload(ctx context.Context) (*Table, error) {
+	rates, err := c.load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("загрузка курсов валют: %w", err)
+	}
+	return NewTable(rates)
+}
+
+// Converter переводит суммы между любыми валютами через Base
+type Converter struct {
+	provider Provider
+}
+
+// NewConverter создаёт конвертер. Без провайдера переводить можно только валюту саму в себя.
+func NewConverter(p Provider) *Converter {
+	return &Converter{provider: p}
+}
+
+// Convert переводит сумму из from в to по курсам на дату day и округляет до копейки к чётному
+func (c *Converter) Convert(ctx context.Context, a money.Amount, from, to string, day time.Time) (money.Amount, error) {
+	if from == to || a == 0 {
+		return a, nil
+	}
+	fromRate, err := c.rate(ctx, from, day)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := c.rate(ctx, to, day)
+	if err != nil {
+		return 0, err
+	}
+	return money.FromDecimal(a.Decimal().Mul(fromRate).Div(toRate))
+}
+
+func (c *Converter) rate(ctx context.Context, currency string, day time.Time) (decimal.Decimal, error) {
+	if currency == Base {
+		return decimal.NewFromInt(1), nil
+	}
+	if c == nil || c.provider == nil {
+		return decimal.Decimal{}, fmt.Errorf("%s: источник курсов не настроен: %w", currency, ErrNoRate)
+	}
+	return c.provider.Rate(ctx, currency, day)
+}
@@ -0,0 +1,134 @@
+package exchange_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/exchange"
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestConverter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.yaml")
+	data := "rates:\n  \"2025-05-01\": {KZT: 0.16, BYN: 25.5}\n  \"2025-05-10\": {KZT: 0.2}\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	table, err := exchange.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv := exchange.NewConverter(table)
+
+	tests := []struct {
+		name     string
+		amount   money.Amount
+		from, to string
+		day      string
+		want     money.Amount
+		wantErr  error
+	}{
+		{"same currency", 12345, "KZT", "KZT", "2020-01-01", 12345, nil},
+		{"to base", 1000_00, "KZT", "RUB", "2025-05-01", 160_00, nil},
+		{"latest earlier rate", 1000_00, "KZT", "RUB", "2025-05-09", 160_00, nil},
+		{"rate changes", 1000_00, "KZT", "RUB", "2025-05-31", 200_00, nil},
+		{"from base", 100_00, "RUB", "BYN", "2025-05-02", 3_92, nil},
+		{"cross rate", 100_00, "BYN", "KZT", "2025-05-02", 15937_50, nil},
+		{"half to even", 1, "KZT", "RUB", "2025-05-10", 0, nil},
+		{"before first rate", 100, "KZT", "RUB", "2025-04-30", 0, exchange.ErrNoRate},
+		{"unknown currency", 100, "AMD", "RUB", "2025-05-01", 0, exchange.ErrNoRate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := conv.Convert(context.Background(), tt.amount, tt.from, tt.to, date(tt.day).Add(13*time.Hour))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverterWithoutProvider(t *testing.T) {
+	conv := exchange.NewConverter(nil)
+	if got, err := conv.Convert(context.Background(), 100, "KZT", "KZT", time.Now()); err != nil || got != 100 {
+		t.Errorf("same currency = %s, %v", got, err)
+	}
+	if _, err := conv.Convert(context.Background(), 100, "KZT", "RUB", time.Now()); !errors.Is(err, exchange.ErrNoRate) {
+		t.Errorf("err = %v, want ErrNoRate", err)
+	}
+}
+
+func TestNewTableRejectsBadRates(t *testing.T) {
+	_, err := exchange.NewTable([]exchange.Rate{
+		{Day: date("2025-05-01"), Currency: "kzt", Rate: decimal.NewFromInt(1)},
+		{Day: date("2025-05-01"), Currency: "BYN", Rate: decimal.Zero},
+	})
+	if !errors.Is(err, models.ErrInvalidCurrency) || !errors.Is(err, exchange.ErrInvalidRate) {
+		t.Errorf("err = %v, want both ErrInvalidCurrency and ErrInvalidRate", err)
+	}
+}
+
+func TestCachedKeepsTableOnError(t *testing.T) {
+	var (
+		fail  bool
+		loads int
+	)
+	cached := exchange.NewCached(func(context.Context) ([]exchange.Rate, error) {
+		loads++
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return []exchange.Rate{{Day: date("2025-05-01"), Currency: "KZT", Rate: decimal.RequireFromString("0.16")}}, nil
+	}, 0)
+
+	ctx := context.Background()
+	if _, err := cached.Rate(ctx, "KZT", date("2025-05-02")); err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	for range 2 {
+		rate, err := cached.Rate(ctx, "KZT", date("2025-05-02"))
+		if err != nil || !rate.Equal(decimal.RequireFromString("0.16")) {
+			t.Errorf("Rate() = %s, %v, want previous table", rate, err)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("loads = %d, want 2: after a failed reload the stale table is served until backoff ends", loads)
+	}
+}
+
+func TestCachedBacksOffAfterError(t *testing.T) {
+	var loads int
+	cached := exchange.NewCached(func(context.Context) ([]exchange.Rate, error) {
+		loads++
+		return nil, errors.New("connection refused")
+	}, 0)
+
+	ctx := context.Background()
+	for range 3 {
+		if _, err := cached.Rate(ctx, "KZT", date("2025-05-02")); err == nil || !strings.Contains(err.Error(), "connection refused") {
+			t.Errorf("Rate() error = %v, want load error", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1: retries must wait for backoff", loads)
+	}
+}
@@ -0,0 +1,76 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+var errUnknownFormat = errors.New("поддерживаются только файлы .yaml, .yml и .json")
+
+// ratesFile - формат файла курсов: дата - валюта - рублей за единицу валюты
+//
+//	rates:
+//	  "2025-05-01": {KZT: 0.16, BYN: 25.1}
+type ratesFile struct {
+	Rates map[string]map[string]decimal.Decimal `yaml:"rates" json:"rates"`
+}
+
+// LoadFile читает курсы из YAML/JSON файла и строит по ним таблицу
+func LoadFile(path string) (*Table, error) {
+	rates, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	table, err := NewTable(rates)
+	if err != nil {
+		return nil, fmt.Errorf("курсы валют %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// ReadFile читает курсы из YAML/JSON файла; формат определяется по расширению
+func ReadFile(path string) ([]Rate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("чтение курсов валют: %w", err)
+	}
+
+	var f ratesFile
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &f)
+	case ".json":
+		err = json.Unmarshal(data, &f)
+	default:
+		err = errUnknownFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("разбор курсов валют %s: %w", path, err)
+	}
+
+	var (
+		rates []Rate
+		errs  []error
+	)
+	for date, byCurrency := range f.Rates {
+		day, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("дата %q: ожидается ГГГГ-ММ-ДД", date))
+			continue
+		}
+		for currency, rate := range byCurrency {
+			rates = append(rates, Rate{Day: day, Currency: currency, Rate: rate})
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("курсы валют %s: %w", path, err)
+	}
+	return rates, nil
+}
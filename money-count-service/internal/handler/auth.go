@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/Qwental/wb-money/internal/auth"
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// moneyServicePrefix - авторизация нужна только методам MoneyService, reflection и health остаются открытыми
+var moneyServicePrefix = "/" + proto.MoneyService_ServiceDesc.ServiceName + "/"
+
+const unauthorizedMessage = "Нет доступа к данным пользователя"
+
+var errForbidden = errors.New("нет прав на данные этого пользователя")
+
+// userRequest - запрос по одному пользователю
+type userRequest interface {
+	GetUserId() int64
+}
+
+// AuthUnaryInterceptor проверяет JWT из заголовка authorization и пускает к данным пользователя
+// только его самого или внутренний сервис. Отказ возвращается статусом UNAUTHORIZED в теле ответа,
+// как и остальные ошибки. Одинаково работает для gRPC и gRPC-Web: обёртка вызывает тот же сервер.
+func AuthUnaryInterceptor(v *auth.Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, moneyServicePrefix) {
+			return handler(ctx, req)
+		}
+
+		id, err := authenticate(ctx, v)
+		if err == nil && !authorized(id, req) {
+			err = errForbidden
+		}
+		if err != nil {
+			logging.FromContext(ctx).Warn("access denied", slog.Any("error", err))
+			return unauthorizedResponse(info.FullMethod)
+		}
+
+		return handler(auth.WithIdentity(ctx, id), req)
+	}
+}
+
+// AuthStreamInterceptor - то же для потоков. Выгрузки по когортам доступны только внутренним сервисам.
+func AuthStreamInterceptor(v *auth.Verifier) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !strings.HasPrefix(info.FullMethod, moneyServicePrefix) {
+			return handler(srv, ss)
+		}
+
+		id, err := authenticate(ss.Context(), v)
+		if err == nil && !id.Service {
+			err = errForbidden
+		}
+		if err != nil {
+			logging.FromContext(ss.Context()).Warn("access denied", slog.Any("error", err))
+			return ss.SendMsg(&proto.UserSavings{
+				Savings: &proto.GetSavingsResponse{
+					Status:  proto.GetSavingsResponse_UNAUTHORIZED,
+					Message: unauthorizedMessage,
+				},
+			})
+		}
+
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: auth.WithIdentity(ss.Context(), id)})
+	}
+}
+
+func authenticate(ctx context.Context, v *auth.Verifier) (auth.Identity, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = auth.BearerToken(values[0])
+		}
+	}
+	return v.Verify(token)
+}
+
+// authorized проверяет доступ к запрошенным данным. Некорректный user_id пропускаем:
+// данных по нему нет, а обработчик вернёт INVALID_REQUEST. Запросы по многим пользователям - только для сервисов.
+func authorized(id auth.Identity, req any) bool {
+	r, ok := req.(userRequest)
+	if !ok {
+		return id.Service
+	}
+	if r.GetUserId() <= 0 {
+		return true
+	}
+	return id.CanAccess(uint64(r.GetUserId()))
+}
+
+// unauthorizedResponse собирает ответ метода со статусом UNAUTHORIZED. Тип ответа берётся
+// из описания сервиса, поэтому новые методы не нужно сюда добавлять.
+func unauthorizedResponse(fullMethod string) (any, error) {
+	denied := status.Error(codes.PermissionDenied, unauthorizedMessage)
+
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil, denied
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, denied
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, denied
+	}
+	md := sd.Methods().ByName(protoreflect.Name(methodName))
+	if md == nil {
+		return nil, denied
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+	if err != nil {
+		return nil, denied
+	}
+
+	m := mt.New()
+	fields := m.Descriptor().Fields()
+	if f := fields.ByName("status"); f != nil && f.Kind() == protoreflect.EnumKind {
+		m.Set(f, protoreflect.ValueOfEnum(proto.GetSavingsResponse_UNAUTHORIZED.Number()))
+	}
+	if f := fields.ByName("message"); f != nil && f.Kind() == protoreflect.StringKind {
+		m.Set(f, protoreflect.ValueOfString(unauthorizedMessage))
+	}
+	return m.Interface(), nil
+}
@@ -0,0 +1,109 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/auth"
+	"github.com/Qwental/wb-money/internal/handler"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var testSecret = []byte("test-secret")
+
+func signToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func TestAuthUnaryInterceptor(t *testing.T) {
+	verifier, err := auth.NewHMACVerifier(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := handler.AuthUnaryInterceptor(verifier)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	userToken := signToken(t, jwt.MapClaims{"sub": "1342", "exp": exp})
+	serviceToken := signToken(t, jwt.MapClaims{"sub": "crm", "role": auth.RoleService, "exp": exp})
+	expiredToken := signToken(t, jwt.MapClaims{"sub": "1342", "exp": time.Now().Add(-time.Hour).Unix()})
+	noExpToken := signToken(t, jwt.MapClaims{"sub": "1342"})
+
+	tests := []struct {
+		name    string
+		method  string
+		token   string
+		req     any
+		allowed bool
+	}{
+		{"own savings", "GetSavings", userToken, &proto.GetSavingsRequest{UserId: 1342}, true},
+		{"other user's savings", "GetSavings", userToken, &proto.GetSavingsRequest{UserId: 1000}, false},
+		{"service reads any user", "GetCashbackTier", serviceToken, &proto.GetCashbackTierRequest{UserId: 1000}, true},
+		{"batch needs service role", "BatchGetSavings", userToken, &proto.BatchGetSavingsRequest{UserIds: []int64{1342}}, false},
+		{"service batch", "BatchGetSavings", serviceToken, &proto.BatchGetSavingsRequest{UserIds: []int64{1342}}, true},
+		{"no token", "GetSavings", "", &proto.GetSavingsRequest{UserId: 1342}, false},
+		{"expired token", "GetSavings", expiredToken, &proto.GetSavingsRequest{UserId: 1342}, false},
+		{"token without exp", "GetSavings", noExpToken, &proto.GetSavingsRequest{UserId: 1342}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tt.token))
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/money_service.MoneyService/" + tt.method}
+
+			called := false
+			resp, err := interceptor(ctx, tt.req, info, func(ctx context.Context, req any) (any, error) {
+				called = true
+				if _, ok := auth.FromContext(ctx); !ok {
+					t.Error("identity not stored in context")
+				}
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if called != tt.allowed {
+				t.Fatalf("handler called = %v, want %v", called, tt.allowed)
+			}
+			if tt.allowed {
+				return
+			}
+
+			st, ok := resp.(interface {
+				GetStatus() proto.GetSavingsResponse_Status
+			})
+			if !ok || st.GetStatus() != proto.GetSavingsResponse_UNAUTHORIZED {
+				t.Errorf("response = %v, want UNAUTHORIZED", resp)
+			}
+		})
+	}
+}
+
+func TestAuthUnaryInterceptorSkipsOtherServices(t *testing.T) {
+	verifier, err := auth.NewHMACVerifier(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	called := false
+	_, err = handler.AuthUnaryInterceptor(verifier)(context.Background(), nil, info,
+		func(ctx context.Context, req any) (any, error) {
+			called = true
+			return nil, nil
+		})
+	if err != nil || !called {
+		t.Errorf("health check blocked: called=%v err=%v", called, err)
+	}
+}
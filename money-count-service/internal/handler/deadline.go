@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/grpc"
+)
+
+// Timeouts - предельное время вызовов: своё для методов из Methods (полное имя метода,
+// например proto.MoneyService_StreamSavings_FullMethodName) и Default для остальных
+type Timeouts struct {
+	Default time.Duration
+	Methods map[string]time.Duration
+}
+
+// NewTimeouts собирает Timeouts из времён по коротким именам методов MoneyService (GetSavings)
+func NewTimeouts(fallback time.Duration, byMethod map[string]time.Duration) (Timeouts, error) {
+	known := map[string]bool{}
+	for _, m := range proto.MoneyService_ServiceDesc.Methods {
+		known[m.MethodName] = true
+	}
+	for _, s := range proto.MoneyService_ServiceDesc.Streams {
+		known[s.StreamName] = true
+	}
+
+	t := Timeouts{Default: fallback, Methods: make(map[string]time.Duration, len(byMethod))}
+	for name, timeout := range byMethod {
+		if !known[name] {
+			return Timeouts{}, fmt.Errorf("таймаут для неизвестного метода %q", name)
+		}
+		t.Methods["/"+proto.MoneyService_ServiceDesc.ServiceName+"/"+name] = timeout
+	}
+	return t, nil
+}
+
+// For возвращает предельное время метода
+func (t Timeouts) For(fullMethod string) time.Duration {
+	if timeout, ok := t.Methods[fullMethod]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// withDeadline ограничивает контекст временем timeout. Дедлайн клиента сохраняется, если он раньше:
+// сервер не работает дольше, чем клиент готов ждать, и не дольше timeout.
+func withDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// DeadlineUnaryInterceptor задаёт вызову дедлайн по умолчанию для его метода
+func DeadlineUnaryInterceptor(t Timeouts) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := withDeadline(ctx, t.For(info.FullMethod))
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// DeadlineStreamInterceptor - то же для потоков: вся выгрузка должна уложиться во время метода,
+// иначе текущий запрос к ClickHouse прерывается и поток завершается кодом DEADLINE_EXCEEDED
+func DeadlineStreamInterceptor(t Timeouts) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := withDeadline(ss.Context(), t.For(info.FullMethod))
+		defer cancel()
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/handler"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/grpc"
+)
+
+func TestDeadlineUnaryInterceptor(t *testing.T) {
+	timeouts, err := handler.NewTimeouts(time.Second, map[string]time.Duration{"GetCashbackTier": 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewTimeouts: %v", err)
+	}
+	interceptor := handler.DeadlineUnaryInterceptor(timeouts)
+
+	tests := []struct {
+		name       string
+		method     string
+		clientWait time.Duration // 0 - клиент не задал дедлайн
+		wantMax    time.Duration
+	}{
+		{"no client deadline", proto.MoneyService_GetSavings_FullMethodName, 0, time.Second},
+		{"client waits longer", proto.MoneyService_GetSavings_FullMethodName, time.Hour, time.Second},
+		{"client waits less", proto.MoneyService_GetSavings_FullMethodName, 100 * time.Millisecond, 100 * time.Millisecond},
+		{"method timeout", proto.MoneyService_GetCashbackTier_FullMethodName, time.Hour, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.clientWait > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.clientWait)
+				defer cancel()
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+			_, _ = interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+				deadline, ok := ctx.Deadline()
+				if !ok {
+					t.Fatal("handler has no deadline")
+				}
+				if left := time.Until(deadline); left > tt.wantMax {
+					t.Errorf("deadline in %v, want at most %v", left, tt.wantMax)
+				}
+				return nil, nil
+			})
+		})
+	}
+}
+
+// contextStream - ServerStream, у которого есть только контекст
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context { return s.ctx }
+
+func TestDeadlineStreamInterceptor(t *testing.T) {
+	timeouts, err := handler.NewTimeouts(time.Hour, map[string]time.Duration{"StreamSavings": 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewTimeouts: %v", err)
+	}
+	interceptor := handler.DeadlineStreamInterceptor(timeouts)
+	info := &grpc.StreamServerInfo{FullMethod: proto.MoneyService_StreamSavings_FullMethodName, IsServerStream: true}
+
+	err = interceptor(nil, contextStream{ctx: context.Background()}, info, func(_ any, ss grpc.ServerStream) error {
+		select {
+		case <-ss.Context().Done():
+			return ss.Context().Err()
+		case <-time.After(time.Second):
+			t.Error("stream was not bounded by its method timeout")
+			return nil
+		}
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestNewTimeoutsRejectsUnknownMethod(t *testing.T) {
+	if _, err := handler.NewTimeouts(time.Second, map[string]time.Duration{"GetSaving": time.Second}); err == nil {
+		t.Error("NewTimeouts accepted unknown method")
+	}
+}
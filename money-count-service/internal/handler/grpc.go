@@ -2,10 +2,16 @@ package handler
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/Qwental/wb-money/internal/logging"
 	"github.com/Qwental/wb-money/internal/service"
 	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
 type MoneyHandler struct {
@@ -19,21 +25,40 @@ func NewMoneyHandler(svc *service.MoneyService) *MoneyHandler {
 
 func (h *MoneyHandler) GetSavings(ctx context.Context, req *proto.GetSavingsRequest) (*proto.GetSavingsResponse, error) {
 	// Логируем входящий запрос
-	log.Printf("- запрос GetSavings для пользователя: %d", req.UserId)
+	logger := logging.FromContext(ctx).With(logging.UserID(uint64(req.UserId)))
+	logger.Debug("get savings")
 
 	// Валидация запроса
 	if req.UserId <= 0 {
-		log.Printf("Некорректный User ID: %d", req.UserId)
+		logger.Info("invalid user id")
 		return &proto.GetSavingsResponse{
 			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
 			Message: "User ID должен быть положительным числом",
 		}, nil
 	}
 
+	period, err := service.TimeRangeFromRequest(req, time.Now())
+	if err != nil {
+		logger.Info("invalid period", slog.Any("error", err))
+		return &proto.GetSavingsResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: fmt.Sprintf("Некорректный период: %v", err),
+		}, nil
+	}
+
+	currency, err := service.CurrencyFromRequest(req)
+	if err != nil {
+		logger.Info("invalid currency", slog.Any("error", err))
+		return &proto.GetSavingsResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: fmt.Sprintf("Некорректная валюта: %v", err),
+		}, nil
+	}
+
 	// Вызываем сервис
-	response, err := h.svc.GetSavings(ctx, uint64(req.UserId))
+	response, err := h.svc.GetSavings(ctx, uint64(req.UserId), period, currency)
 	if err != nil {
-		log.Printf("Ошибка сервиса для пользователя %d: %v", req.UserId, err)
+		logger.Error("service failed", slog.Any("error", err))
 		return &proto.GetSavingsResponse{
 			Status:  proto.GetSavingsResponse_UNKNOWN_ERROR,
 			Message: "Внутренняя ошибка сервера",
@@ -41,8 +66,189 @@ func (h *MoneyHandler) GetSavings(ctx context.Context, req *proto.GetSavingsRequ
 	}
 
 	// Логируем результат
-	log.Printf("- Результат для пользователя %d: статус=%s, экономия=%.2f, покупок=%d, wb=%d",
-		req.UserId, response.Status.String(), response.TotalSavings, response.TotalPurchases, response.WbCardPurchases)
+	logger.Info("savings computed",
+		slog.String("status", response.Status.String()),
+		slog.Float64("total_savings", response.TotalSavings),
+		slog.String("currency", response.Currency),
+		slog.Int("total_purchases", int(response.TotalPurchases)),
+		slog.Int("wb_card_purchases", int(response.WbCardPurchases)))
+
+	return response, nil
+}
+
+func (h *MoneyHandler) GetSavingsHistory(ctx context.Context, req *proto.GetSavingsHistoryRequest) (*proto.GetSavingsHistoryResponse, error) {
+	logger := logging.FromContext(ctx).With(logging.UserID(uint64(req.UserId)))
+	logger.Debug("get savings history", slog.String("granularity", req.Granularity.String()))
+
+	if req.UserId <= 0 {
+		logger.Info("invalid user id")
+		return &proto.GetSavingsHistoryResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: "User ID должен быть положительным числом",
+		}, nil
+	}
+
+	period, err := service.TimeRangeFromRequest(req, time.Now())
+	if err != nil {
+		logger.Info("invalid period", slog.Any("error", err))
+		return &proto.GetSavingsHistoryResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: fmt.Sprintf("Некорректный период: %v", err),
+		}, nil
+	}
+
+	currency, err := service.CurrencyFromRequest(req)
+	if err != nil {
+		logger.Info("invalid currency", slog.Any("error", err))
+		return &proto.GetSavingsHistoryResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: fmt.Sprintf("Некорректная валюта: %v", err),
+		}, nil
+	}
+
+	response, err := h.svc.GetSavingsHistory(ctx, uint64(req.UserId), period, req.Granularity, currency)
+	if err != nil {
+		logger.Error("service failed", slog.Any("error", err))
+		return &proto.GetSavingsHistoryResponse{
+			Status:  proto.GetSavingsResponse_UNKNOWN_ERROR,
+			Message: "Внутренняя ошибка сервера",
+		}, nil
+	}
+
+	logger.Info("savings history computed",
+		slog.String("status", response.Status.String()),
+		slog.Int("buckets", len(response.Buckets)))
+
+	return response, nil
+}
+
+func (h *MoneyHandler) GetCashbackTier(ctx context.Context, req *proto.GetCashbackTierRequest) (*proto.GetCashbackTierResponse, error) {
+	logger := logging.FromContext(ctx).With(logging.UserID(uint64(req.UserId)))
+	logger.Debug("get cashback tier")
+
+	if req.UserId <= 0 {
+		logger.Info("invalid user id")
+		return &proto.GetCashbackTierResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: "User ID должен быть положительным числом",
+		}, nil
+	}
+
+	response, err := h.svc.GetCashbackTier(ctx, uint64(req.UserId))
+	if err != nil {
+		logger.Error("service failed", slog.Any("error", err))
+		return &proto.GetCashbackTierResponse{
+			Status:  proto.GetSavingsResponse_UNKNOWN_ERROR,
+			Message: "Внутренняя ошибка сервера",
+		}, nil
+	}
+
+	logger.Info("cashback tier computed",
+		slog.String("status", response.Status.String()),
+		slog.Int("level", int(response.Level)),
+		slog.Int("wallet_purchases", int(response.WalletPurchases)))
+
+	return response, nil
+}
+
+func (h *MoneyHandler) BatchGetSavings(ctx context.Context, req *proto.BatchGetSavingsRequest) (*proto.BatchGetSavingsResponse, error) {
+	logger := logging.FromContext(ctx).With(slog.Int("users", len(req.UserIds)))
+	logger.Debug("batch get savings")
+
+	if len(req.UserIds) == 0 || len(req.UserIds) > service.MaxBatchUsers {
+		logger.Info("invalid batch size")
+		return &proto.BatchGetSavingsResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: fmt.Sprintf("Нужно передать от 1 до %d user_ids", service.MaxBatchUsers),
+		}, nil
+	}
+
+	period, err := service.TimeRangeFromRequest(req, time.Now())
+	if err != nil {
+		logger.Info("invalid period", slog.Any("error", err))
+		return &proto.BatchGetSavingsResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: fmt.Sprintf("Некорректный период: %v", err),
+		}, nil
+	}
+
+	currency, err := service.CurrencyFromRequest(req)
+	if err != nil {
+		logger.Info("invalid currency", slog.Any("error", err))
+		return &proto.BatchGetSavingsResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: fmt.Sprintf("Некорректная валюта: %v", err),
+		}, nil
+	}
+
+	response, err := h.svc.BatchGetSavings(ctx, req.UserIds, period, currency)
+	if err != nil {
+		logger.Error("service failed", slog.Any("error", err))
+		return &proto.BatchGetSavingsResponse{
+			Status:  proto.GetSavingsResponse_UNKNOWN_ERROR,
+			Message: "Внутренняя ошибка сервера",
+		}, nil
+	}
+
+	logger.Info("batch savings computed",
+		slog.String("status", response.Status.String()),
+		slog.Int("results", len(response.Results)))
 
 	return response, nil
 }
+
+func (h *MoneyHandler) StreamSavings(req *proto.StreamSavingsRequest, stream grpc.ServerStreamingServer[proto.UserSavings]) error {
+	ctx := stream.Context()
+	logger := logging.FromContext(ctx)
+	logger.Debug("stream savings", slog.Any("cohort", req.Cohort))
+
+	cohort, err := service.CohortFromRequest(req.Cohort)
+	if err != nil {
+		logger.Info("invalid cohort", slog.Any("error", err))
+		return sendStreamStatus(stream, proto.GetSavingsResponse_INVALID_REQUEST, fmt.Sprintf("Некорректная когорта: %v", err))
+	}
+
+	period, err := service.TimeRangeFromRequest(req, time.Now())
+	if err != nil {
+		logger.Info("invalid period", slog.Any("error", err))
+		return sendStreamStatus(stream, proto.GetSavingsResponse_INVALID_REQUEST, fmt.Sprintf("Некорректный период: %v", err))
+	}
+
+	currency, err := service.CurrencyFromRequest(req)
+	if err != nil {
+		logger.Info("invalid currency", slog.Any("error", err))
+		return sendStreamStatus(stream, proto.GetSavingsResponse_INVALID_REQUEST, fmt.Sprintf("Некорректная валюта: %v", err))
+	}
+
+	var sent int
+	err = h.svc.StreamSavings(ctx, cohort, period, currency, int(req.PageSize), func(u *proto.UserSavings) error {
+		sent++
+		return stream.Send(u)
+	})
+	switch {
+	case err == nil:
+		logger.Info("stream finished", slog.Int("users", sent))
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		logger.Warn("stream deadline exceeded", slog.Int("users", sent), slog.Any("error", ctx.Err()))
+		return status.FromContextError(ctx.Err()).Err()
+	case ctx.Err() != nil:
+		logger.Info("stream cancelled by client", slog.Int("users", sent), slog.Any("error", ctx.Err()))
+		return status.FromContextError(ctx.Err()).Err()
+	case errors.Is(err, service.ErrInvalidPageSize):
+		return sendStreamStatus(stream, proto.GetSavingsResponse_INVALID_REQUEST, err.Error())
+	case service.IsTimeout(err):
+		logger.Error("stream query timed out", slog.Int("users", sent), slog.Any("error", err))
+		return sendStreamStatus(stream, proto.GetSavingsResponse_TIMEOUT, "Превышено время ожидания ответа базы данных")
+	default:
+		logger.Error("stream failed", slog.Int("users", sent), slog.Any("error", err))
+		return sendStreamStatus(stream, proto.GetSavingsResponse_DB_ERROR, "Ошибка доступа к базе данных")
+	}
+}
+
+// sendStreamStatus сообщает об ошибке всей выгрузки записью без user_id, как это делают унарные методы
+func sendStreamStatus(stream grpc.ServerStreamingServer[proto.UserSavings], st proto.GetSavingsResponse_Status, message string) error {
+	return stream.Send(&proto.UserSavings{
+		Savings: &proto.GetSavingsResponse{Status: st, Message: message},
+	})
+}
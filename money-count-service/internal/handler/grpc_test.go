@@ -0,0 +1,123 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/internal/exchange"
+	"github.com/Qwental/wb-money/internal/handler"
+	"github.com/Qwental/wb-money/internal/service"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient поднимает MoneyService на событиях в памяти и возвращает клиента к нему
+func newTestClient(t *testing.T, events ...database.Event) proto.MoneyServiceClient {
+	t.Helper()
+	rules, err := cashback.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	svc := service.NewMoneyService(database.NewMemoryStore(events...), rules, exchange.NewConverter(nil))
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(handler.RequestIDUnaryInterceptor(), handler.StatusCodesUnaryInterceptor(false)),
+		grpc.ChainStreamInterceptor(handler.RequestIDStreamInterceptor(), handler.StatusCodesStreamInterceptor(false)),
+	)
+	proto.RegisterMoneyServiceServer(server, handler.NewMoneyHandler(svc))
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return proto.NewMoneyServiceClient(conn)
+}
+
+func TestMoneyServiceRPC(t *testing.T) {
+	ts := time.Date(2025, time.May, 2, 12, 0, 0, 0, time.UTC)
+	client := newTestClient(t,
+		database.Event{Timestamp: ts, UserID: 1342, EventName: "buy", Parameters: `{"amount":1000,"payment_method":"card"}`},
+		database.Event{Timestamp: ts, UserID: 1342, EventName: "buy", Parameters: `{"amount":320,"payment_method":"wallet"}`},
+	)
+	ctx := context.Background()
+
+	t.Run("GetSavings", func(t *testing.T) {
+		var header metadata.MD
+		resp, err := client.GetSavings(ctx, &proto.GetSavingsRequest{UserId: 1342}, grpc.Header(&header))
+		if err != nil {
+			t.Fatalf("GetSavings: %v", err)
+		}
+		if resp.Status != proto.GetSavingsResponse_OK || resp.TotalSavings != 30 || resp.TotalPurchases != 2 ||
+			resp.WbCardPurchases != 1 || resp.Currency != "RUB" {
+			t.Errorf("response = %v", resp)
+		}
+		if m := resp.Savings; m.GetUnits() != 30 || m.GetNanos() != 0 || m.GetCurrency() != "RUB" {
+			t.Errorf("response = %v", resp)
+		}
+		if len(header.Get(handler.RequestIDHeader)) != 1 {
+			t.Errorf("no %s header in response", handler.RequestIDHeader)
+		}
+	})
+
+	t.Run("statuses in body", func(t *testing.T) {
+		tests := []struct {
+			req  *proto.GetSavingsRequest
+			want proto.GetSavingsResponse_Status
+		}{
+			{&proto.GetSavingsRequest{UserId: -1}, proto.GetSavingsResponse_INVALID_REQUEST},
+			{&proto.GetSavingsRequest{UserId: 7}, proto.GetSavingsResponse_USER_NOT_FOUND},
+			{&proto.GetSavingsRequest{UserId: 1342, Period: proto.GetSavingsRequest_Period(99)}, proto.GetSavingsResponse_INVALID_REQUEST},
+			{&proto.GetSavingsRequest{UserId: 1342, Currency: "rub"}, proto.GetSavingsResponse_INVALID_REQUEST},
+			{&proto.GetSavingsRequest{UserId: 1342, Currency: "KZT"}, proto.GetSavingsResponse_RATES_UNAVAILABLE},
+		}
+		for _, tt := range tests {
+			resp, err := client.GetSavings(ctx, tt.req)
+			if err != nil || resp.Status != tt.want {
+				t.Errorf("GetSavings(%v) = %v, %v; want status %v", tt.req, resp, err, tt.want)
+			}
+		}
+	})
+
+	t.Run("GetCashbackTier", func(t *testing.T) {
+		resp, err := client.GetCashbackTier(ctx, &proto.GetCashbackTierRequest{UserId: 1342})
+		if err != nil || resp.Status != proto.GetSavingsResponse_OK || resp.WalletPurchases != 1 {
+			t.Errorf("GetCashbackTier = %v, %v", resp, err)
+		}
+	})
+
+	t.Run("StreamSavings", func(t *testing.T) {
+		stream, err := client.StreamSavings(ctx, &proto.StreamSavingsRequest{})
+		if err != nil {
+			t.Fatalf("StreamSavings: %v", err)
+		}
+		var users []int64
+		for {
+			u, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Recv: %v", err)
+			}
+			users = append(users, u.UserId)
+		}
+		if len(users) != 1 || users[0] != 1342 {
+			t.Errorf("streamed users = %v, want [1342]", users)
+		}
+	})
+}
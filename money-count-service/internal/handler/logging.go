@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/Qwental/wb-money/internal/logging"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader - заголовок с ID запроса; если клиент его не передал, ID генерируется
+// и возвращается в заголовках ответа
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLen - длиннее клиентский ID не принимаем, чтобы не раздувать логи
+const maxRequestIDLen = 64
+
+// RequestIDUnaryInterceptor кладёт в контекст логгер с request_id, методом и trace_id.
+// Стоит первым в цепочке, чтобы этими полями были помечены записи всех остальных перехватчиков.
+func RequestIDUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		id := requestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+		return handler(logging.WithLogger(ctx, requestLogger(ctx, id, info.FullMethod)), req)
+	}
+}
+
+// RequestIDStreamInterceptor - то же для потоков
+func RequestIDStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		id := requestID(ctx)
+		_ = ss.SetHeader(metadata.Pairs(RequestIDHeader, id))
+		ctx = logging.WithLogger(ctx, requestLogger(ctx, id, info.FullMethod))
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func requestLogger(ctx context.Context, id, method string) *slog.Logger {
+	logger := slog.Default().With(slog.String("request_id", id), slog.String("method", method))
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		logger = logger.With(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return logger
+}
+
+// requestID берёт ID запроса из заголовка клиента или генерирует новый
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 && validRequestID(values[0]) {
+			return values[0]
+		}
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/metrics"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsUnaryInterceptor записывает длительность вызова и статусы выданных GetSavingsResponse.
+// Стоит после перевода статусов в gRPC-коды, поэтому видит статус в теле ответа независимо
+// от режима, выбранного клиентом.
+func MetricsUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		metrics.RPCDuration.WithLabelValues(info.FullMethod, rpcStatus(resp, err)).Observe(time.Since(start).Seconds())
+		switch r := resp.(type) {
+		case *proto.GetSavingsResponse:
+			countSavings(r)
+		case *proto.BatchGetSavingsResponse:
+			for _, u := range r.GetResults() {
+				countSavings(u.GetSavings())
+			}
+		}
+		return resp, err
+	}
+}
+
+// MetricsStreamInterceptor - то же для потоков; статус выгрузки берётся из записи без user_id
+func MetricsStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ms := &metricsStream{ServerStream: ss}
+		err := handler(srv, ms)
+
+		st := status.Code(err).String()
+		if err == nil && ms.failure != nil {
+			st = ms.failure.String()
+		}
+		metrics.RPCDuration.WithLabelValues(info.FullMethod, st).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+type metricsStream struct {
+	grpc.ServerStream
+	failure *proto.GetSavingsResponse_Status
+}
+
+func (s *metricsStream) SendMsg(m any) error {
+	if u, ok := m.(*proto.UserSavings); ok {
+		if u.UserId == 0 {
+			st := u.Savings.GetStatus()
+			s.failure = &st
+		} else {
+			countSavings(u.GetSavings())
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// rpcStatus - метка статуса вызова: gRPC-код ошибки или статус в теле ответа
+func rpcStatus(resp any, err error) string {
+	if err != nil {
+		return status.Code(err).String()
+	}
+	if r, ok := resp.(statusResponse); ok {
+		return r.GetStatus().String()
+	}
+	return "OK"
+}
+
+func countSavings(r *proto.GetSavingsResponse) {
+	if r != nil {
+		metrics.SavingsResponses.WithLabelValues(r.GetStatus().String()).Inc()
+	}
+}
@@ -0,0 +1,45 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Qwental/wb-money/internal/handler"
+	"github.com/Qwental/wb-money/internal/metrics"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+)
+
+func TestMetricsUnaryInterceptorCountsSavingsStatuses(t *testing.T) {
+	notFound := metrics.SavingsResponses.WithLabelValues("USER_NOT_FOUND")
+	noPurchases := metrics.SavingsResponses.WithLabelValues("NO_PURCHASES")
+	beforeNotFound := testutil.ToFloat64(notFound)
+	beforeNoPurchases := testutil.ToFloat64(noPurchases)
+
+	interceptor := handler.MetricsUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/money_service.MoneyService/BatchGetSavings"}
+	resp := &proto.BatchGetSavingsResponse{
+		Results: []*proto.UserSavings{
+			{UserId: 1, Savings: &proto.GetSavingsResponse{Status: proto.GetSavingsResponse_USER_NOT_FOUND}},
+			{UserId: 2, Savings: &proto.GetSavingsResponse{Status: proto.GetSavingsResponse_USER_NOT_FOUND}},
+			{UserId: 3, Savings: &proto.GetSavingsResponse{Status: proto.GetSavingsResponse_NO_PURCHASES}},
+		},
+	}
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return resp, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.ToFloat64(notFound) - beforeNotFound; got != 2 {
+		t.Errorf("USER_NOT_FOUND count = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(noPurchases) - beforeNoPurchases; got != 1 {
+		t.Errorf("NO_PURCHASES count = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(metrics.RPCDuration); n == 0 {
+		t.Error("rpc duration not recorded")
+	}
+}
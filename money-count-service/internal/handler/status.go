@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// StatusCodesHeader - заголовок, которым клиент включает (или выключает) gRPC-коды для своего вызова
+const StatusCodesHeader = "x-grpc-status-codes"
+
+// errorDomain - домен в errdetails.ErrorInfo; Reason в нём - имя статуса из GetSavingsResponse
+const errorDomain = "money_service"
+
+// dbRetryDelay - через сколько клиенту имеет смысл повторить запрос после DB_ERROR
+const dbRetryDelay = time.Second
+
+// statusResponse - любой ответ сервиса со статусом в теле
+type statusResponse interface {
+	GetStatus() proto.GetSavingsResponse_Status
+	GetMessage() string
+}
+
+// StatusCodesUnaryInterceptor превращает ошибочный статус в теле ответа в настоящий gRPC-статус
+// с errdetails. По умолчанию режим задаётся сервером (enabled), клиент может переопределить его
+// заголовком StatusCodesHeader. Без этого режима ответы остаются как раньше - ошибка только в теле.
+func StatusCodesUnaryInterceptor(enabled bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err != nil || !statusCodesEnabled(ctx, enabled) {
+			return resp, err
+		}
+		if r, ok := resp.(statusResponse); ok {
+			if st := statusFromResponse(r.GetStatus(), r.GetMessage()); st != nil {
+				return nil, st.Err()
+			}
+		}
+		return resp, nil
+	}
+}
+
+// StatusCodesStreamInterceptor делает то же для потоков: запись об ошибке всей выгрузки
+// (UserSavings без user_id) заменяется gRPC-статусом
+func StatusCodesStreamInterceptor(enabled bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !statusCodesEnabled(ss.Context(), enabled) {
+			return handler(srv, ss)
+		}
+		return handler(srv, &statusCodesStream{ServerStream: ss})
+	}
+}
+
+type statusCodesStream struct {
+	grpc.ServerStream
+}
+
+func (s *statusCodesStream) SendMsg(m any) error {
+	if u, ok := m.(*proto.UserSavings); ok && u.UserId == 0 {
+		if st := statusFromResponse(u.Savings.GetStatus(), u.Savings.GetMessage()); st != nil {
+			return st.Err()
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func statusCodesEnabled(ctx context.Context, enabled bool) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return enabled
+	}
+	values := md.Get(StatusCodesHeader)
+	if len(values) == 0 {
+		return enabled
+	}
+	v, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return enabled
+	}
+	return v
+}
+
+// statusFromResponse возвращает gRPC-статус для ошибочного статуса в теле или nil, если это не ошибка.
+// NO_PURCHASES - нормальный результат, а не ошибка. RATES_UNAVAILABLE тоже не ошибка: в ответе
+// остаётся разбивка по валютам покупок, которую gRPC-ошибка бы потеряла.
+func statusFromResponse(st proto.GetSavingsResponse_Status, message string) *status.Status {
+	var code codes.Code
+	switch st {
+	case proto.GetSavingsResponse_OK, proto.GetSavingsResponse_NO_PURCHASES, proto.GetSavingsResponse_RATES_UNAVAILABLE:
+		return nil
+	case proto.GetSavingsResponse_INVALID_REQUEST:
+		code = codes.InvalidArgument
+	case proto.GetSavingsResponse_USER_NOT_FOUND:
+		code = codes.NotFound
+	case proto.GetSavingsResponse_DB_ERROR:
+		code = codes.Unavailable
+	case proto.GetSavingsResponse_UNAUTHORIZED:
+		code = codes.PermissionDenied
+	case proto.GetSavingsResponse_TIMEOUT:
+		code = codes.DeadlineExceeded
+	default:
+		code = codes.Internal
+	}
+
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{Reason: st.String(), Domain: errorDomain},
+	}
+	if st == proto.GetSavingsResponse_DB_ERROR {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(dbRetryDelay)})
+	}
+
+	s := status.New(code, message)
+	if withDetails, err := s.WithDetails(details...); err == nil {
+		return withDetails
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Qwental/wb-money/internal/handler"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusCodesUnaryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/money_service.MoneyService/GetSavings"}
+	respond := func(st proto.GetSavingsResponse_Status) grpc.UnaryHandler {
+		return func(ctx context.Context, req any) (any, error) {
+			return &proto.GetSavingsResponse{Status: st, Message: "message"}, nil
+		}
+	}
+	optIn := metadata.NewIncomingContext(context.Background(), metadata.Pairs(handler.StatusCodesHeader, "true"))
+	optOut := metadata.NewIncomingContext(context.Background(), metadata.Pairs(handler.StatusCodesHeader, "false"))
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		enabled  bool
+		status   proto.GetSavingsResponse_Status
+		wantCode codes.Code
+	}{
+		{"legacy mode keeps in-band status", context.Background(), false, proto.GetSavingsResponse_DB_ERROR, codes.OK},
+		{"server mode maps not found", context.Background(), true, proto.GetSavingsResponse_USER_NOT_FOUND, codes.NotFound},
+		{"no purchases is not an error", context.Background(), true, proto.GetSavingsResponse_NO_PURCHASES, codes.OK},
+		{"missing rate keeps breakdown in body", context.Background(), true, proto.GetSavingsResponse_RATES_UNAVAILABLE, codes.OK},
+		{"header opts in", optIn, false, proto.GetSavingsResponse_INVALID_REQUEST, codes.InvalidArgument},
+		{"header opts out", optOut, true, proto.GetSavingsResponse_DB_ERROR, codes.OK},
+		{"timeout maps to deadline exceeded", context.Background(), true, proto.GetSavingsResponse_TIMEOUT, codes.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := handler.StatusCodesUnaryInterceptor(tt.enabled)(tt.ctx, nil, info, respond(tt.status))
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("code = %s, want %s", got, tt.wantCode)
+			}
+			if tt.wantCode == codes.OK {
+				if resp.(*proto.GetSavingsResponse).Status != tt.status {
+					t.Errorf("in-band status changed: %v", resp)
+				}
+				return
+			}
+
+			details := status.Convert(err).Details()
+			if len(details) == 0 {
+				t.Fatal("no error details")
+			}
+			if info, ok := details[0].(*errdetails.ErrorInfo); !ok || info.Reason != tt.status.String() {
+				t.Errorf("details = %v, want ErrorInfo with reason %s", details, tt.status)
+			}
+		})
+	}
+}
+
+func TestStatusCodesUnaryInterceptorRetryInfo(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/money_service.MoneyService/GetSavings"}
+	_, err := handler.StatusCodesUnaryInterceptor(true)(context.Background(), nil, info,
+		func(ctx context.Context, req any) (any, error) {
+			return &proto.GetSavingsResponse{Status: proto.GetSavingsResponse_DB_ERROR}, nil
+		})
+
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("code = %s, want Unavailable", status.Code(err))
+	}
+	for _, d := range status.Convert(err).Details() {
+		if _, ok := d.(*errdetails.RetryInfo); ok {
+			return
+		}
+	}
+	t.Error("DB_ERROR without RetryInfo")
+}
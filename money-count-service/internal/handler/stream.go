@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// wrappedStream подменяет контекст потока, чтобы интерсепторы могли передать обработчику
+// дополнительные значения или дедлайн
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedStream) Context() context.Context {
+	return s.ctx
+}
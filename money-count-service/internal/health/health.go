@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// eventsTable - без этой таблицы сервису нечего отдавать
+const eventsTable = "product_events"
+
+var (
+	errNotChecked   = errors.New("проверка ещё не выполнялась")
+	errShuttingDown = errors.New("сервис останавливается")
+	errNoTable      = fmt.Errorf("нет таблицы %s", eventsTable)
+)
+
+// Probe проверяет зависимость и возвращает причину неготовности
+type Probe func(ctx context.Context) error
+
+// ClickHouseProbe проверяет, что ClickHouse отвечает и в нём есть таблица событий
+func ClickHouseProbe(db *sqlx.DB) Probe {
+	return func(ctx context.Context) error {
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("ClickHouse недоступен: %w", err)
+		}
+		var exists uint8
+		if err := db.QueryRowContext(ctx, "EXISTS TABLE "+eventsTable).Scan(&exists); err != nil {
+			return fmt.Errorf("проверка таблицы %s: %w", eventsTable, err)
+		}
+		if exists == 0 {
+			return errNoTable
+		}
+		return nil
+	}
+}
+
+// Checker периодически выполняет проверку и публикует результат в grpc.health.v1
+// и через HTTP /readyz. Живость (/livez) от зависимостей не зависит.
+type Checker struct {
+	probe    Probe
+	interval time.Duration
+	server   *grpchealth.Server
+	services []string
+
+	mu       sync.RWMutex
+	err      error
+	shutdown bool
+}
+
+// NewChecker создаёт проверку; services - имена gRPC-сервисов, чей статус она выставляет
+// (общий статус сервера "" выставляется всегда). До первой проверки сервис не готов.
+func NewChecker(probe Probe, interval time.Duration, services ...string) *Checker {
+	c := &Checker{
+		probe:    probe,
+		interval: interval,
+		server:   grpchealth.NewServer(),
+		services: append([]string{""}, services...),
+		err:      errNotChecked,
+	}
+	c.publish(healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// Server - реализация grpc.health.v1.Health для регистрации на gRPC сервере
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// Run проверяет зависимости сразу и затем каждые interval, пока не отменён ctx
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check выполняет одну проверку и обновляет статус
+func (c *Checker) Check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+	err := c.probe(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shutdown {
+		return
+	}
+	if (err == nil) != (c.err == nil) {
+		if err != nil {
+			slog.Warn("service is not ready", slog.Any("error", err))
+		} else {
+			slog.Info("service is ready")
+		}
+	}
+	c.err = err
+
+	if err != nil {
+		c.publish(healthpb.HealthCheckResponse_NOT_SERVING)
+	} else {
+		c.publish(healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+// Shutdown навсегда снимает готовность; вызывается первым при остановке сервиса
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shutdown = true
+	c.err = errShuttingDown
+	c.server.Shutdown()
+}
+
+// Ready возвращает nil, если сервис готов принимать запросы, иначе причину
+func (c *Checker) Ready() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.err
+}
+
+// LivezHandler отвечает 200, пока процесс жив и обслуживает HTTP
+func (c *Checker) LivezHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	})
+}
+
+// ReadyzHandler отвечает 200, если сервис готов, и 503 с причиной, если нет
+func (c *Checker) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	})
+}
+
+func (c *Checker) publish(st healthpb.HealthCheckResponse_ServingStatus) {
+	for _, name := range c.services {
+		c.server.SetServingStatus(name, st)
+	}
+}
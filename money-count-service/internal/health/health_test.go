@@ -0,0 +1,76 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const service = "money_service.MoneyService"
+
+func servingStatus(t *testing.T, c *health.Checker, name string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := c.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: name})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", name, err)
+	}
+	return resp.GetStatus()
+}
+
+func readyzCode(c *health.Checker) int {
+	rec := httptest.NewRecorder()
+	c.ReadyzHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	return rec.Code
+}
+
+func TestChecker(t *testing.T) {
+	var probeErr error
+	c := health.NewChecker(func(context.Context) error { return probeErr }, time.Second, service)
+
+	// до первой проверки сервис не готов
+	if got := readyzCode(c); got != http.StatusServiceUnavailable {
+		t.Errorf("readyz before check = %d, want 503", got)
+	}
+
+	c.Check(context.Background())
+	if got := readyzCode(c); got != http.StatusOK {
+		t.Errorf("readyz = %d, want 200", got)
+	}
+	for _, name := range []string{"", service} {
+		if got := servingStatus(t, c, name); got != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("status(%q) = %v, want SERVING", name, got)
+		}
+	}
+
+	probeErr = errors.New("connection refused")
+	c.Check(context.Background())
+	if got := readyzCode(c); got != http.StatusServiceUnavailable {
+		t.Errorf("readyz with failing probe = %d, want 503", got)
+	}
+	if got := servingStatus(t, c, service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status = %v, want NOT_SERVING", got)
+	}
+
+	// после Shutdown успешные проверки готовность не возвращают, а живость остаётся
+	probeErr = nil
+	c.Shutdown()
+	c.Check(context.Background())
+	if got := readyzCode(c); got != http.StatusServiceUnavailable {
+		t.Errorf("readyz after shutdown = %d, want 503", got)
+	}
+	if got := servingStatus(t, c, service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status after shutdown = %v, want NOT_SERVING", got)
+	}
+
+	rec := httptest.NewRecorder()
+	c.LivezHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("livez = %d, want 200", rec.Code)
+	}
+}
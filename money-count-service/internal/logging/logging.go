@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// UserIDMode - как user_id попадает в логи
+type UserIDMode string
+
+const (
+	UserIDPlain UserIDMode = "plain" // как есть, только для локальной отладки
+	UserIDHash  UserIDMode = "hash"  // HMAC-SHA256 с солью: записи одного пользователя связываются, ID не раскрывается
+	UserIDMask  UserIDMode = "mask"  // видны только две последние цифры
+)
+
+// Config - настройки логирования
+type Config struct {
+	Level      string // debug, info, warn, error
+	Format     string // json или text
+	UserIDMode UserIDMode
+	UserIDSalt string
+}
+
+// userIDs - текущий режим вывода user_id; задаётся один раз в Setup
+var userIDs = userIDFormatter{mode: UserIDHash}
+
+// Setup создаёт логгер по конфигурации и делает его логгером по умолчанию,
+// в том числе для оставшихся вызовов стандартного log
+func Setup(w io.Writer, cfg Config) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("некорректный уровень логирования %q", cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("некорректный формат логов %q: ожидается json или text", cfg.Format)
+	}
+
+	switch cfg.UserIDMode {
+	case "":
+		cfg.UserIDMode = UserIDHash
+	case UserIDPlain, UserIDHash, UserIDMask:
+	default:
+		return nil, fmt.Errorf("некорректный режим user_id %q: ожидается plain, hash или mask", cfg.UserIDMode)
+	}
+	userIDs = userIDFormatter{mode: cfg.UserIDMode, salt: []byte(cfg.UserIDSalt)}
+
+	logger := slog.New(h)
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+type loggerKey struct{}
+
+// WithLogger сохраняет логгер запроса (с request_id и т.п.) в контексте
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext возвращает логгер запроса или логгер по умолчанию
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// UserID - атрибут user_id в выбранном режиме: как есть, хэш или маска
+func UserID(id uint64) slog.Attr {
+	return slog.String("user_id", userIDs.format(id))
+}
+
+type userIDFormatter struct {
+	mode UserIDMode
+	salt []byte
+}
+
+func (f userIDFormatter) format(id uint64) string {
+	s := strconv.FormatUint(id, 10)
+	switch f.mode {
+	case UserIDPlain:
+		return s
+	case UserIDMask:
+		if len(s) <= 2 {
+			return "**"
+		}
+		return strings.Repeat("*", len(s)-2) + s[len(s)-2:]
+	default:
+		mac := hmac.New(sha256.New, f.salt)
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+}
+
+// RedactDSN скрывает пароль в строке подключения
+func RedactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "<invalid dsn>"
+	}
+	q := u.Query()
+	for _, key := range []string{"password", "secret", "token"} {
+		if q.Has(key) {
+			q.Set(key, "xxxxx")
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.Redacted()
+}
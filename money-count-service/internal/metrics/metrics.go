@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "money_service"
+
+// Registry - реестр метрик сервиса; отдельный от глобального, чтобы тесты и библиотеки
+// не добавляли в /metrics ничего лишнего
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RPCDuration - длительность вызовов по методу и итоговому статусу: статусу в теле ответа
+	// (OK, USER_NOT_FOUND, ...) или gRPC-коду, если вызов завершился ошибкой
+	RPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_duration_seconds",
+		Help:      "Duration of gRPC and gRPC-Web calls by method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "status"})
+
+	// QueryDuration - длительность запросов к ClickHouse по имени запроса
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_query_duration_seconds",
+		Help:      "Duration of ClickHouse queries by query name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+
+	// QueryErrors - ошибки запросов к ClickHouse по имени запроса
+	QueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_query_errors_total",
+		Help:      "Failed ClickHouse queries by query name.",
+	}, []string{"query"})
+
+	// SavingsResponses - выданные GetSavingsResponse по статусу, включая ответы внутри
+	// BatchGetSavings и StreamSavings
+	SavingsResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "savings_responses_total",
+		Help:      "GetSavingsResponse messages returned, by status.",
+	}, []string{"status"})
+
+	// MalformedRows - строки событий с невалидным JSON в parameters, пропущенные при подсчёте
+	MalformedRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "malformed_event_rows_total",
+		Help:      "Event rows skipped because parameters is not valid JSON, by query name.",
+	}, []string{"query"})
+
+	// SavingsSource - расчёты экономии по источнику: raw (события) или aggregate (почасовые агрегаты)
+	SavingsSource = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "savings_source_total",
+		Help:      "Savings computations by data source: raw events or hourly aggregates.",
+	}, []string{"source"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RPCDuration,
+		QueryDuration,
+		QueryErrors,
+		SavingsResponses,
+		MalformedRows,
+		SavingsSource,
+	)
+}
+
+// ObserveQuery записывает длительность запроса и ошибку, если она была
+func ObserveQuery(query string, start time.Time, err error) {
+	QueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+	if err != nil {
+		QueryErrors.WithLabelValues(query).Inc()
+	}
+}
+
+// RegisterCORSRejected публикует счётчик запросов, отклонённых из-за origin
+func RegisterCORSRejected(rejected func() uint64) {
+	Registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cors_rejected_total",
+		Help:      "Browser requests rejected because their Origin is not allowed.",
+	}, func() float64 { return float64(rejected()) }))
+}
+
+// Handler отдаёт метрики в формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
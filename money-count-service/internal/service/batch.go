@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/pkg/proto"
+)
+
+// MaxBatchUsers - сколько пользователей можно запросить в одном BatchGetSavings
+const MaxBatchUsers = 1000
+
+// BatchGetSavings считает экономию в валюте currency сразу для многих пользователей одним запросом к ClickHouse.
+// Повторяющиеся ID возвращаются один раз, некорректные получают INVALID_REQUEST, не ломая остальные.
+func (s *MoneyService) BatchGetSavings(ctx context.Context, userIDs []int64, period TimeRange, currency string) (*proto.BatchGetSavingsResponse, error) {
+	var (
+		order []int64
+		valid []uint64
+		seen  = make(map[int64]bool, len(userIDs))
+	)
+	for _, id := range userIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		order = append(order, id)
+		if id > 0 {
+			valid = append(valid, uint64(id))
+		}
+	}
+
+	rules := s.rules.Rules()
+	aggs := map[uint64]*database.SavingsAggregate{}
+	if len(valid) > 0 {
+		var err error
+		aggs, err = s.store.SavingsAggregates(ctx, rules, valid, period)
+		if err != nil {
+			logging.FromContext(ctx).Error("savings aggregates query failed", slog.Int("users", len(valid)), slog.Any("error", err))
+			st, message := dbFailure(err, "Ошибка доступа к базе данных")
+			return &proto.BatchGetSavingsResponse{Status: st, Message: message}, nil
+		}
+	}
+
+	results := make([]*proto.UserSavings, 0, len(order))
+	for _, id := range order {
+		var savings *proto.GetSavingsResponse
+		if id <= 0 {
+			savings = &proto.GetSavingsResponse{
+				Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+				Message: "User ID должен быть положительным числом",
+			}
+		} else {
+			savings = s.savingsResponse(ctx, uint64(id), rules, aggs[uint64(id)], currency)
+		}
+		results = append(results, &proto.UserSavings{UserId: id, Savings: savings})
+	}
+
+	return &proto.BatchGetSavingsResponse{
+		Status:  proto.GetSavingsResponse_OK,
+		Results: results,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Qwental/wb-money/internal/exchange"
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/proto"
+)
+
+// CurrencyRequest - запрос, в котором можно выбрать валюту ответа
+type CurrencyRequest interface {
+	GetCurrency() string
+}
+
+// CurrencyFromRequest возвращает валюту ответа; пустая строка означает рубли
+func CurrencyFromRequest(req CurrencyRequest) (string, error) {
+	currency := req.GetCurrency()
+	if currency == "" {
+		return models.DefaultCurrency, nil
+	}
+	if !models.ValidCurrency(currency) {
+		return "", fmt.Errorf("%q: %w", currency, models.ErrInvalidCurrency)
+	}
+	return currency, nil
+}
+
+// currencySign - знак валюты для сообщений; для валют без привычного знака - её код
+func currencySign(currency string) string {
+	if currency == models.DefaultCurrency {
+		return "₽"
+	}
+	return currency
+}
+
+// conversionFailure - статус и сообщение ответа, если экономию не удалось перевести в валюту ответа:
+// RATES_UNAVAILABLE, если нет курса, иначе статус ошибки запроса курсов
+func conversionFailure(err error, currency string) (proto.GetSavingsResponse_Status, string) {
+	if errors.Is(err, exchange.ErrNoRate) {
+		return proto.GetSavingsResponse_RATES_UNAVAILABLE, fmt.Sprintf("Не удалось перевести экономию в %s: %v", currency, err)
+	}
+	return dbFailure(err, "Ошибка получения курсов валют")
+}
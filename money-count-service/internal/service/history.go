@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/pkg/money"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var ErrInvalidGranularity = errors.New("неизвестный шаг разбиения")
+
+// historyBucket - покупки и экономия за один интервал
+type historyBucket struct {
+	Start   time.Time
+	Savings money.Amount
+	agg     *database.SavingsAggregate // покупки интервала в том же виде, что для GetSavings
+}
+
+// bucketStart возвращает начало интервала, в который попадает t, и функцию перехода
+// к следующему интервалу. Интервалы считаются в UTC, как дни курсов валют и упущенных покупок,
+// поэтому границы недель и месяцев не зависят от часового пояса сервера.
+func bucketStart(t time.Time, g proto.GetSavingsHistoryRequest_Granularity) (time.Time, func(time.Time) time.Time, error) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch g {
+	case proto.GetSavingsHistoryRequest_DAY:
+		return day, func(b time.Time) time.Time { return b.AddDate(0, 0, 1) }, nil
+	case proto.GetSavingsHistoryRequest_WEEK:
+		// Неделя начинается с понедельника
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset), func(b time.Time) time.Time { return b.AddDate(0, 0, 7) }, nil
+	case proto.GetSavingsHistoryRequest_MONTH:
+		return day.AddDate(0, 0, 1-day.Day()), func(b time.Time) time.Time { return b.AddDate(0, 1, 0) }, nil
+	default:
+		return time.Time{}, nil, ErrInvalidGranularity
+	}
+}
+
+// savingsHistory раскладывает покупки по интервалам. Интервалы без покупок между первой
+// и последней покупкой тоже попадают в результат, чтобы на графике не было разрывов.
+// Покупки интервала собираются в агрегат, как у хранилища для GetSavings, и экономию интервала
+// считает savings: суммы переводятся по правилу, валюте и дню, кэшбек округляется по правилу
+// и валюте. Если все покупки периода попали в один интервал, его экономия равна GetSavings.
+func savingsHistory(purchases []cashback.Purchase, rules *cashback.RuleSet, g proto.GetSavingsHistoryRequest_Granularity,
+	savings func(*database.SavingsAggregate) (money.Amount, error)) ([]historyBucket, error) {
+	var buckets []historyBucket
+	for _, p := range purchases {
+		start, next, err := bucketStart(p.Timestamp, g)
+		if err != nil {
+			return nil, err
+		}
+		if len(buckets) == 0 {
+			buckets = append(buckets, historyBucket{Start: start})
+		}
+		for last := buckets[len(buckets)-1].Start; last.Before(start); last = next(last) {
+			buckets = append(buckets, historyBucket{Start: next(last)})
+		}
+
+		b := &buckets[len(buckets)-1]
+		if b.agg == nil {
+			b.agg = database.NewSavingsAggregate(0)
+		}
+		b.agg.AddPurchase(rules, p)
+	}
+
+	for i := range buckets {
+		if buckets[i].agg == nil {
+			continue
+		}
+		amount, err := savings(buckets[i].agg)
+		if err != nil {
+			return nil, err
+		}
+		buckets[i].Savings = amount
+	}
+	return buckets, nil
+}
+
+// GetSavingsHistory раскладывает экономию пользователя за период по интервалам в валюте currency
+func (s *MoneyService) GetSavingsHistory(ctx context.Context, userID uint64, period TimeRange,
+	granularity proto.GetSavingsHistoryRequest_Granularity, currency string) (*proto.GetSavingsHistoryResponse, error) {
+	if userID == 0 {
+		return &proto.GetSavingsHistoryResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: "User ID не может быть пустым",
+		}, nil
+	}
+
+	if _, _, err := bucketStart(time.Time{}, granularity); err != nil {
+		return &proto.GetSavingsHistoryResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: err.Error(),
+		}, nil
+	}
+
+	// Уровень пользователя нужен до основного запроса: его надбавка входит в ставку
+	stats, err := s.store.UserStats(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("wallet stats query failed", logging.UserID(userID), slog.Any("error", err))
+		st, message := dbFailure(err, "Ошибка доступа к базе данных")
+		return &proto.GetSavingsHistoryResponse{Status: st, Message: message}, nil
+	}
+	if !stats.UserExists {
+		return &proto.GetSavingsHistoryResponse{
+			Status:  proto.GetSavingsResponse_USER_NOT_FOUND,
+			Message: fmt.Sprintf("Пользователь с ID %d не найден", userID),
+		}, nil
+	}
+
+	rules := s.rules.Rules()
+	tier, _ := rules.TierFor(int(stats.WalletOrders))
+	purchases, err := s.store.Purchases(ctx, userID, period)
+	if err != nil {
+		logging.FromContext(ctx).Error("purchases query failed", logging.UserID(userID), slog.Any("error", err))
+		st, message := dbFailure(err, "Ошибка получения истории покупок")
+		return &proto.GetSavingsHistoryResponse{Status: st, Message: message}, nil
+	}
+	rows, err := savingsHistory(purchases, rules, granularity, func(agg *database.SavingsAggregate) (money.Amount, error) {
+		b, err := s.savingsBreakdown(ctx, rules, tier, agg, currency)
+		return b.total, err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("currency conversion failed", logging.UserID(userID),
+			slog.String("currency", currency), slog.Any("error", err))
+		st, message := conversionFailure(err, currency)
+		return &proto.GetSavingsHistoryResponse{Status: st, Message: message}, nil
+	}
+
+	if len(rows) == 0 {
+		return &proto.GetSavingsHistoryResponse{
+			Status:   proto.GetSavingsResponse_NO_PURCHASES,
+			Currency: currency,
+			Message:  "У пользователя нет покупок",
+		}, nil
+	}
+
+	buckets := make([]*proto.SavingsBucket, 0, len(rows))
+	for _, row := range rows {
+		bucket := &proto.SavingsBucket{Start: timestamppb.New(row.Start), Savings: row.Savings.Float64()}
+		if row.agg != nil {
+			bucket.Purchases = int32(row.agg.TotalPurchases)
+			bucket.WbCardPurchases = int32(row.agg.WalletPurchases)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return &proto.GetSavingsHistoryResponse{
+		Status:   proto.GetSavingsResponse_OK,
+		Currency: currency,
+		Buckets:  buckets,
+	}, nil
+}
@@ -2,29 +2,36 @@ package service
 
 import (
 	"context"
-	_ "database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/internal/exchange"
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/pkg/money"
 	"github.com/Qwental/wb-money/pkg/proto"
-	"github.com/jmoiron/sqlx"
 )
 
+// MoneyService считает экономию и уровень кэшбека по событиям из EventStore
 type MoneyService struct {
-	db *sqlx.DB
-}
-
-type BuyEvent struct {
-	Amount        float64 `json:"amount"`
-	PaymentMethod string  `json:"payment_method"`
+	store database.EventStore
+	rules *cashback.Engine
+	rates *exchange.Converter
 }
 
-func NewMoneyService(db *sqlx.DB) *MoneyService {
-	return &MoneyService{db: db}
+// NewMoneyService создаёт сервис поверх хранилища событий. rates переводит покупки в валюту ответа;
+// без него экономию можно получить только в валюте, в которой сделаны все покупки.
+func NewMoneyService(store database.EventStore, rules *cashback.Engine, rates *exchange.Converter) *MoneyService {
+	return &MoneyService{store: store, rules: rules, rates: rates}
 }
 
-func (s *MoneyService) GetSavings(ctx context.Context, userID uint64) (*proto.GetSavingsResponse, error) {
+// GetSavings считает экономию пользователя за период в валюте currency
+func (s *MoneyService) GetSavings(ctx context.Context, userID uint64, period TimeRange, currency string) (*proto.GetSavingsResponse, error) {
 	// Валидация входных данных
 	if userID == 0 {
 		return &proto.GetSavingsResponse{
@@ -33,118 +40,201 @@ func (s *MoneyService) GetSavings(ctx context.Context, userID uint64) (*proto.Ge
 		}, nil
 	}
 
-	// Проверяем существование пользователя
-	var userExists bool
-	checkUserQuery := `SELECT count(*) > 0 FROM product_events WHERE user_id = ?`
-	err := s.db.GetContext(ctx, &userExists, checkUserQuery, userID)
+	// Правила берём один раз, чтобы перечитывание файла не повлияло на запрос посередине
+	rules := s.rules.Rules()
+	aggs, err := s.store.SavingsAggregates(ctx, rules, []uint64{userID}, period)
 	if err != nil {
-		log.Printf("Ошибка проверки пользователя %d: %v", userID, err)
-		return &proto.GetSavingsResponse{
-			Status:  proto.GetSavingsResponse_DB_ERROR,
-			Message: "Ошибка доступа к базе данных",
-		}, nil
+		logging.FromContext(ctx).Error("savings aggregates query failed", logging.UserID(userID), slog.Any("error", err))
+		st, message := dbFailure(err, "Ошибка доступа к базе данных")
+		return &proto.GetSavingsResponse{Status: st, Message: message}, nil
 	}
 
-	if !userExists {
+	return s.savingsResponse(ctx, userID, rules, aggs[userID], currency), nil
+}
+
+// savingsResponse формирует ответ по агрегатам пользователя; nil означает, что событий у него нет
+func (s *MoneyService) savingsResponse(ctx context.Context, userID uint64, rules *cashback.RuleSet,
+	agg *database.SavingsAggregate, currency string) *proto.GetSavingsResponse {
+	if agg == nil {
 		return &proto.GetSavingsResponse{
 			Status:  proto.GetSavingsResponse_USER_NOT_FOUND,
 			Message: fmt.Sprintf("Пользователь с ID %d не найден", userID),
-		}, nil
-	}
-
-	// Получаем данные о покупках
-	query := `
-        SELECT parameters
-        FROM product_events
-        WHERE user_id = ? AND event_name = 'buy'
-    `
-
-	rows, err := s.db.QueryxContext(ctx, query, userID)
-	if err != nil {
-		log.Printf("Ошибка выполнения запроса для пользователя %d: %v", userID, err)
-		return &proto.GetSavingsResponse{
-			Status:  proto.GetSavingsResponse_DB_ERROR,
-			Message: "Ошибка получения данных о покупках",
-		}, nil
-	}
-
-	defer func(rows *sqlx.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Printf("error closing rows: %v", err)
-		}
-	}(rows)
-
-	var (
-		totalSavings      float64
-		totalPurchases    int32
-		wbCardPurchases   int32
-		hasValidPurchases bool
-	)
-
-	for rows.Next() {
-		var params string
-		if err := rows.Scan(&params); err != nil {
-			log.Printf("Ошибка сканирования данных для пользователя %d: %v", userID, err)
-			continue
-		}
-
-		var event BuyEvent
-		if err := json.Unmarshal([]byte(params), &event); err != nil {
-			log.Printf("Ошибка парсинга JSON для пользователя %d: %v", userID, err)
-			continue
-		}
-
-		// Увеличиваем счетчик покупок
-		totalPurchases++
-		hasValidPurchases = true
-
-		// Если метод оплаты не кошелёк, добавляем 3% от суммы к экономии
-		if event.PaymentMethod != "wallet" {
-			totalSavings += event.Amount * 0.03
-		} else if event.PaymentMethod == "wallet" {
-			wbCardPurchases++
-			log.Printf("event.PaymentMethod = %s, wbCardPurchases%d\n", event.PaymentMethod, wbCardPurchases)
 		}
 	}
 
-	// Проверяем ошибки при итерации
-	if err := rows.Err(); err != nil {
-		log.Printf("Ошибка итерации по результатам для пользователя %d: %v", userID, err)
-		return &proto.GetSavingsResponse{
-			Status:  proto.GetSavingsResponse_DB_ERROR,
-			Message: "Ошибка обработки данных",
-		}, nil
-	}
+	totalPurchases := int32(agg.TotalPurchases)
+	wbCardPurchases := int32(agg.WalletPurchases)
 
 	// Если у пользователя нет покупок
-	if !hasValidPurchases {
+	if totalPurchases == 0 {
 		return &proto.GetSavingsResponse{
 			Status:          proto.GetSavingsResponse_NO_PURCHASES,
 			TotalSavings:    0,
-			Currency:        "RUB",
+			Currency:        currency,
 			TotalPurchases:  0,
 			WbCardPurchases: 0,
 			Message:         "У пользователя нет покупок",
-		}, nil
+			Savings:         moneyProto(0, currency),
+		}
+	}
+
+	tier, _ := rules.TierFor(int(agg.WalletOrders))
+	b, err := s.savingsBreakdown(ctx, rules, tier, agg, currency)
+	if errors.Is(err, exchange.ErrNoRate) {
+		// Без курса итог в валюте ответа не посчитать, но экономия в валютах покупок известна
+		logging.FromContext(ctx).Warn("exchange rate unavailable", logging.UserID(userID),
+			slog.String("currency", currency), slog.Any("error", err))
+		st, message := conversionFailure(err, currency)
+		return &proto.GetSavingsResponse{
+			Status:          st,
+			Currency:        currency,
+			TotalPurchases:  totalPurchases,
+			WbCardPurchases: wbCardPurchases,
+			Message:         message,
+			TierLevel:       int32(tier.Level),
+			ByCurrency:      b.byCurrency,
+		}
 	}
+	if err != nil {
+		logging.FromContext(ctx).Error("currency conversion failed", logging.UserID(userID),
+			slog.String("currency", currency), slog.Any("error", err))
+		st, message := conversionFailure(err, currency)
+		return &proto.GetSavingsResponse{Status: st, Message: message}
+	}
+	totalSavings := b.total
 
 	// Формируем сообщение
 	var message string
 	if totalSavings > 0 {
-		message = fmt.Sprintf("Вы сэкономили %.2f ₽ благодаря WB Card! Покупок с картой: %d из %d",
-			totalSavings, wbCardPurchases, totalPurchases)
+		message = fmt.Sprintf("Вы сэкономили %s %s благодаря WB Card! Покупок с картой: %d из %d",
+			totalSavings, currencySign(currency), wbCardPurchases, totalPurchases)
 	} else {
-		message = fmt.Sprintf("Пока нет экономии. Используйте WB Card для получения 3%% кэшбека! Всего покупок: %d",
-			totalPurchases)
+		message = fmt.Sprintf("Пока нет экономии. Используйте WB Card для получения%s кэшбека! Всего покупок: %d",
+			percentHint(rules, tier), totalPurchases)
 	}
 
 	return &proto.GetSavingsResponse{
 		Status:          proto.GetSavingsResponse_OK,
-		TotalSavings:    totalSavings,
-		Currency:        "RUB",
+		TotalSavings:    totalSavings.Float64(),
+		Currency:        currency,
 		TotalPurchases:  totalPurchases,
 		WbCardPurchases: wbCardPurchases,
 		Message:         message,
-	}, nil
+		AppliedRules:    b.applied,
+		TierLevel:       int32(tier.Level),
+		Savings:         moneyProto(totalSavings, currency),
+		ByCurrency:      b.byCurrency,
+	}
+}
+
+// percentHint - процент кэшбека по текущим правилам с надбавкой уровня для сообщения: " 3%", если
+// все правила дают одинаковый процент, " до 7%", если разный, и пустая строка без правил
+func percentHint(rules *cashback.RuleSet, tier cashback.Tier) string {
+	if len(rules.Rules) == 0 {
+		return ""
+	}
+	lowest, highest := rules.Rules[0].Percent, rules.Rules[0].Percent
+	for _, r := range rules.Rules[1:] {
+		lowest, highest = min(lowest, r.Percent), max(highest, r.Percent)
+	}
+	if lowest == highest {
+		return fmt.Sprintf(" %g%%", highest+tier.BonusPercent)
+	}
+	return fmt.Sprintf(" до %g%%", highest+tier.BonusPercent)
+}
+
+// breakdown - экономия пользователя по правилам и по валютам покупок
+type breakdown struct {
+	applied    []*proto.AppliedRule
+	byCurrency []*proto.CurrencySavings
+	total      money.Amount // в валюте ответа
+}
+
+// ruleCurrency - упущенные покупки одного правила в одной валюте
+type ruleCurrency struct {
+	rule     string
+	currency string
+}
+
+// savingsBreakdown раскладывает экономию по правилам в порядке их объявления и по валютам покупок.
+// Надбавка уровня пользователя прибавляется к проценту каждого правила. Суммы покупок переводятся
+// в валюту ответа по курсу на день покупки с округлением до копейки к чётному, затем кэшбек
+// считается от суммы покупок каждой пары правило-валюта и округляется до копейки.
+// Итог и экономия правила - суммы округлённых значений, поэтому сходятся с разбивкой по валютам.
+// Если для какой-то валюты нет курса, возвращается ошибка exchange.ErrNoRate вместе с разбивкой
+// по валютам покупок, в которой у валют без курса нет converted_savings.
+func (s *MoneyService) savingsBreakdown(ctx context.Context, rules *cashback.RuleSet, tier cashback.Tier,
+	agg *database.SavingsAggregate, currency string) (breakdown, error) {
+	original := map[ruleCurrency]money.Amount{}
+	converted := map[ruleCurrency]money.Amount{}
+	noRate := map[string]bool{} // валюты без курса
+	for _, m := range agg.Missed {
+		key := ruleCurrency{rule: m.RuleID, currency: m.Currency}
+		original[key] += m.Amount
+		if noRate[m.Currency] {
+			continue
+		}
+		amount, err := s.rates.Convert(ctx, m.Amount, m.Currency, currency, m.Day)
+		if errors.Is(err, exchange.ErrNoRate) {
+			noRate[m.Currency] = true
+			continue
+		}
+		if err != nil {
+			return breakdown{}, err
+		}
+		converted[key] += amount
+	}
+
+	currencies := slices.Sorted(maps.Keys(agg.CurrencyPurchases))
+	saved := map[string]money.Amount{}
+	savedConverted := map[string]money.Amount{}
+
+	var b breakdown
+	for _, r := range rules.Rules {
+		purchases := agg.RulePurchases[r.ID]
+		if purchases == 0 {
+			continue
+		}
+		percent := r.Percent + tier.BonusPercent
+		var savings money.Amount
+		for _, c := range currencies {
+			key := ruleCurrency{rule: r.ID, currency: c}
+			amount := money.Percent(converted[key], percent)
+			savings += amount
+			savedConverted[c] += amount
+			saved[c] += money.Percent(original[key], percent)
+		}
+		b.total += savings
+		b.applied = append(b.applied, &proto.AppliedRule{
+			RuleId:    r.ID,
+			Percent:   percent,
+			Purchases: int32(purchases),
+			Savings:   savings.Float64(),
+		})
+	}
+
+	var missing []string
+	for _, c := range currencies {
+		cs := &proto.CurrencySavings{
+			Currency:  c,
+			Purchases: int32(agg.CurrencyPurchases[c]),
+			Savings:   moneyProto(saved[c], c),
+		}
+		if noRate[c] {
+			missing = append(missing, c)
+		} else {
+			cs.ConvertedSavings = moneyProto(savedConverted[c], currency)
+		}
+		b.byCurrency = append(b.byCurrency, cs)
+	}
+	if len(missing) > 0 {
+		return b, fmt.Errorf("%s: %w", strings.Join(missing, ", "), exchange.ErrNoRate)
+	}
+	return b, nil
+}
+
+// moneyProto переводит сумму в сообщение Money
+func moneyProto(a money.Amount, currency string) *proto.Money {
+	units, nanos := a.Units()
+	return &proto.Money{Units: units, Nanos: nanos, Currency: currency}
 }
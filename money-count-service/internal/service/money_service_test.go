@@ -0,0 +1,430 @@
+package service_test
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/cashback"
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/internal/exchange"
+	"github.com/Qwental/wb-money/internal/service"
+	"github.com/Qwental/wb-money/pkg/money"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"github.com/shopspring/decimal"
+)
+
+// day - момент в UTC, в нём же считаются интервалы истории
+func day(month time.Month, d, hour int) time.Time {
+	return time.Date(2025, month, d, hour, 0, 0, 0, time.UTC)
+}
+
+// newTestService - сервис с правилом по умолчанию (3% с любой покупки) и уровнями
+// +1% за каждые 10 покупок кошельком (не больше +2%) поверх событий в памяти.
+// Пользователь 1 покупал картой, кошельком и наличными и прислал одно битое событие,
+// пользователь 2 только открывал приложение, пользователь 4 один раз купил картой,
+// пользователь 6 покупал картой в тенге и рублях. Курс тенге: 0.2 ₽ с 1 мая, 0.18 ₽ с 15 мая.
+// Пользователь 8 вернул покупку картой в июне и отменил покупку кошельком, осталась одна покупка картой.
+func newTestService(t *testing.T) *service.MoneyService {
+	t.Helper()
+	store := database.NewMemoryStore(
+		database.Event{Timestamp: day(time.May, 1, 10), UserID: 1, EventName: "open_app", Parameters: `{"platform":"ios"}`},
+		database.Event{Timestamp: day(time.May, 2, 12), UserID: 1, EventName: "buy", Parameters: `{"amount":1000,"payment_method":"card","category":"books"}`},
+		database.Event{Timestamp: day(time.May, 5, 9), UserID: 1, EventName: "buy", Parameters: `{"amount":500,"payment_method":"wallet"}`},
+		database.Event{Timestamp: day(time.May, 5, 10), UserID: 1, EventName: "buy", Parameters: `{not json`},
+		database.Event{Timestamp: day(time.May, 20, 18), UserID: 1, EventName: "buy", Parameters: `{"amount":200,"payment_method":"cash"}`},
+		database.Event{Timestamp: day(time.May, 3, 8), UserID: 2, EventName: "open_app", Parameters: `{}`},
+		database.Event{Timestamp: day(time.May, 7, 8), UserID: 4, EventName: "buy", Parameters: `{"amount":100,"payment_method":"card"}`},
+		database.Event{Timestamp: day(time.May, 2, 12), UserID: 6, EventName: "buy", Parameters: `{"amount":10000,"currency":"KZT","payment_method":"card"}`},
+		database.Event{Timestamp: day(time.May, 20, 12), UserID: 6, EventName: "buy", Parameters: `{"amount":5000,"currency":"KZT","payment_method":"card"}`},
+		database.Event{Timestamp: day(time.May, 20, 12), UserID: 6, EventName: "buy", Parameters: `{"amount":1000,"currency":"RUB","payment_method":"card"}`},
+		database.Event{Timestamp: day(time.May, 4, 12), UserID: 8, EventName: "buy", Parameters: `{"amount":1000,"payment_method":"card","order_id":"8-1"}`},
+		database.Event{Timestamp: day(time.May, 6, 12), UserID: 8, EventName: "buy", Parameters: `{"amount":500,"payment_method":"wallet","order_id":"8-2"}`},
+		database.Event{Timestamp: day(time.May, 6, 12), UserID: 8, EventName: "cancel", Parameters: `{"order_id":"8-2"}`},
+		database.Event{Timestamp: day(time.May, 9, 12), UserID: 8, EventName: "buy", Parameters: `{"amount":400,"payment_method":"card","order_id":"8-3"}`},
+		database.Event{Timestamp: day(time.June, 2, 12), UserID: 8, EventName: "refund", Parameters: `{"order_id":"8-1"}`},
+	)
+	rules, err := cashback.NewEngineWithDefault("", &cashback.RuleSet{
+		Rules: cashback.DefaultRuleSet().Rules,
+		Tiers: []cashback.Tier{
+			{Level: 1, MinWalletOrders: 0, BonusPercent: 0},
+			{Level: 2, MinWalletOrders: 10, BonusPercent: 1},
+			{Level: 3, MinWalletOrders: 20, BonusPercent: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	rates, err := exchange.NewTable([]exchange.Rate{
+		{Day: time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC), Currency: "KZT", Rate: decimal.RequireFromString("0.2")},
+		{Day: time.Date(2025, time.May, 15, 0, 0, 0, 0, time.UTC), Currency: "KZT", Rate: decimal.RequireFromString("0.18")},
+	})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	return service.NewMoneyService(store, rules, exchange.NewConverter(rates))
+}
+
+func TestGetSavings(t *testing.T) {
+	svc := newTestService(t)
+
+	tests := []struct {
+		name          string
+		userID        uint64
+		period        service.TimeRange
+		wantStatus    proto.GetSavingsResponse_Status
+		wantSavings   float64
+		wantPurchases int32
+		wantWallet    int32
+	}{
+		{"all time", 1, service.TimeRange{}, proto.GetSavingsResponse_OK, 36, 3, 1},
+		{"period", 1, service.TimeRange{From: day(time.May, 3, 0), To: day(time.May, 31, 0)}, proto.GetSavingsResponse_OK, 6, 2, 1},
+		{"refund after period", 8, service.TimeRange{To: day(time.May, 31, 0)}, proto.GetSavingsResponse_OK, 12, 1, 0},
+		{"only open_app", 2, service.TimeRange{}, proto.GetSavingsResponse_NO_PURCHASES, 0, 0, 0},
+		{"unknown user", 3, service.TimeRange{}, proto.GetSavingsResponse_USER_NOT_FOUND, 0, 0, 0},
+		{"zero user", 0, service.TimeRange{}, proto.GetSavingsResponse_INVALID_REQUEST, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.GetSavings(context.Background(), tt.userID, tt.period, "RUB")
+			if err != nil {
+				t.Fatalf("GetSavings: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Fatalf("status = %v, want %v (%s)", resp.Status, tt.wantStatus, resp.Message)
+			}
+			if math.Abs(resp.TotalSavings-tt.wantSavings) > 1e-9 || resp.TotalPurchases != tt.wantPurchases ||
+				resp.WbCardPurchases != tt.wantWallet {
+				t.Errorf("savings = %v, purchases = %d, wallet = %d; want %v, %d, %d",
+					resp.TotalSavings, resp.TotalPurchases, resp.WbCardPurchases, tt.wantSavings, tt.wantPurchases, tt.wantWallet)
+			}
+		})
+	}
+}
+
+func TestGetSavingsMessagePercent(t *testing.T) {
+	store := database.NewMemoryStore(database.Event{Timestamp: day(time.May, 2, 12), UserID: 1, EventName: "buy",
+		Parameters: `{"amount":100,"payment_method":"wallet"}`})
+
+	tests := []struct {
+		name  string
+		rules *cashback.RuleSet
+		want  string
+	}{
+		{"default percent", cashback.DefaultRuleSetWithPercent(4.5), "получения 4.5% кэшбека"},
+		{"different rules", &cashback.RuleSet{Rules: []cashback.Rule{
+			{ID: "books", Percent: 7, Categories: []string{"books"}},
+			{ID: "default", Percent: 2},
+		}}, "получения до 7% кэшбека"},
+		{"tier bonus", &cashback.RuleSet{
+			Rules: []cashback.Rule{{ID: "default", Percent: 3}},
+			Tiers: []cashback.Tier{{Level: 1, BonusPercent: 1}},
+		}, "получения 4% кэшбека"},
+		{"no rules", &cashback.RuleSet{}, "получения кэшбека"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := cashback.NewEngineWithDefault("", tt.rules)
+			if err != nil {
+				t.Fatalf("NewEngine: %v", err)
+			}
+			svc := service.NewMoneyService(store, rules, exchange.NewConverter(nil))
+			resp, err := svc.GetSavings(context.Background(), 1, service.TimeRange{}, "RUB")
+			if err != nil || resp.Status != proto.GetSavingsResponse_OK || !strings.Contains(resp.Message, tt.want) {
+				t.Errorf("GetSavings = %v, %v; want message with %q", resp, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSavingsInCurrency(t *testing.T) {
+	svc := newTestService(t)
+
+	tests := []struct {
+		name       string
+		currency   string
+		wantStatus proto.GetSavingsResponse_Status
+		wantTotal  string
+		want       []currencySavings
+	}{
+		// 10000 ₸ по 0.2 и 5000 ₸ по 0.18 - 2900 ₽, 3% - 87 ₽; 3% от 1000 ₽ - 30 ₽
+		{"rubles", "RUB", proto.GetSavingsResponse_OK, "117.00", []currencySavings{
+			{"KZT", 2, "450.00", "87.00"},
+			{"RUB", 1, "30.00", "30.00"},
+		}},
+		// 1000 ₽ по курсу 20 мая - 5555.56 ₸, 3% - 166.67 ₸
+		{"tenge", "KZT", proto.GetSavingsResponse_OK, "616.67", []currencySavings{
+			{"KZT", 2, "450.00", "450.00"},
+			{"RUB", 1, "30.00", "166.67"},
+		}},
+		// курса доллара нет: итога нет, разбивка только в валютах покупок
+		{"no rate", "USD", proto.GetSavingsResponse_RATES_UNAVAILABLE, "", []currencySavings{
+			{"KZT", 2, "450.00", ""},
+			{"RUB", 1, "30.00", ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.GetSavings(context.Background(), 6, service.TimeRange{}, tt.currency)
+			if err != nil {
+				t.Fatalf("GetSavings: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Fatalf("status = %v, want %v (%s)", resp.Status, tt.wantStatus, resp.Message)
+			}
+			if tt.wantStatus == proto.GetSavingsResponse_OK {
+				if got := amount(resp.Savings); got != tt.wantTotal || resp.Currency != tt.currency || resp.Savings.Currency != tt.currency {
+					t.Errorf("savings = %s %s, want %s %s", got, resp.Currency, tt.wantTotal, tt.currency)
+				}
+			}
+			checkByCurrency(t, resp.ByCurrency, tt.currency, tt.want)
+		})
+	}
+}
+
+func TestGetSavingsWithoutRates(t *testing.T) {
+	store := database.NewMemoryStore(
+		database.Event{Timestamp: day(time.May, 2, 12), UserID: 1, EventName: "buy", Parameters: `{"amount":10000,"currency":"KZT","payment_method":"card"}`},
+		database.Event{Timestamp: day(time.May, 3, 12), UserID: 1, EventName: "buy", Parameters: `{"amount":1000,"payment_method":"wallet"}`},
+		database.Event{Timestamp: day(time.May, 4, 12), UserID: 1, EventName: "buy", Parameters: `{"amount":2000,"payment_method":"card"}`},
+	)
+	rules, err := cashback.NewEngineWithDefault("", cashback.DefaultRuleSet())
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	// RATES_SOURCE=none: источника курсов нет
+	svc := service.NewMoneyService(store, rules, exchange.NewConverter(nil))
+
+	resp, err := svc.GetSavings(context.Background(), 1, service.TimeRange{}, "RUB")
+	if err != nil {
+		t.Fatalf("GetSavings: %v", err)
+	}
+	if resp.Status != proto.GetSavingsResponse_RATES_UNAVAILABLE || !strings.Contains(resp.Message, "KZT") {
+		t.Fatalf("status = %v (%s), want RATES_UNAVAILABLE for KZT", resp.Status, resp.Message)
+	}
+	if resp.TotalPurchases != 3 || resp.WbCardPurchases != 1 || resp.Currency != "RUB" || resp.Savings != nil {
+		t.Errorf("response = %v", resp)
+	}
+	// рублёвые покупки не требуют курса и переведены в валюту ответа
+	checkByCurrency(t, resp.ByCurrency, "RUB", []currencySavings{
+		{"KZT", 1, "300.00", ""},
+		{"RUB", 2, "60.00", "60.00"},
+	})
+}
+
+// currencySavings - ожидаемая строка by_currency; converted пустая, если курса нет
+type currencySavings struct {
+	currency  string
+	purchases int32
+	savings   string
+	converted string
+}
+
+func checkByCurrency(t *testing.T, got []*proto.CurrencySavings, currency string, want []currencySavings) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("by_currency = %v, want %d entries", got, len(want))
+	}
+	for i, w := range want {
+		c := got[i]
+		row := currencySavings{currency: c.Currency, purchases: c.Purchases, savings: amount(c.Savings)}
+		if c.ConvertedSavings != nil {
+			row.converted = amount(c.ConvertedSavings)
+			if c.ConvertedSavings.Currency != currency {
+				t.Errorf("by_currency[%d] converted to %s, want %s", i, c.ConvertedSavings.Currency, currency)
+			}
+		}
+		if row != w || c.Savings.Currency != w.currency {
+			t.Errorf("by_currency[%d] = %v, want %+v", i, c, w)
+		}
+	}
+}
+
+// amount - сумма Money строкой с двумя знаками
+func amount(m *proto.Money) string {
+	return money.Amount(m.Units*100 + int64(m.Nanos/10_000_000)).String()
+}
+
+func TestGetSavingsHistory(t *testing.T) {
+	svc := newTestService(t)
+
+	resp, err := svc.GetSavingsHistory(context.Background(), 1, service.TimeRange{}, proto.GetSavingsHistoryRequest_WEEK, "RUB")
+	if err != nil {
+		t.Fatalf("GetSavingsHistory: %v", err)
+	}
+	if resp.Status != proto.GetSavingsResponse_OK {
+		t.Fatalf("status = %v (%s)", resp.Status, resp.Message)
+	}
+
+	// Неделя 12 мая без покупок заполняется нулями
+	want := []struct {
+		start     time.Time
+		purchases int32
+		wallet    int32
+		savings   float64
+	}{
+		{day(time.April, 28, 0), 1, 0, 30},
+		{day(time.May, 5, 0), 1, 1, 0},
+		{day(time.May, 12, 0), 0, 0, 0},
+		{day(time.May, 19, 0), 1, 0, 6},
+	}
+	if len(resp.Buckets) != len(want) {
+		t.Fatalf("buckets = %v, want %d", resp.Buckets, len(want))
+	}
+	for i, w := range want {
+		b := resp.Buckets[i]
+		if !b.Start.AsTime().Equal(w.start) || b.Purchases != w.purchases || b.WbCardPurchases != w.wallet ||
+			math.Abs(b.Savings-w.savings) > 1e-9 {
+			t.Errorf("bucket %d = %v, want %+v", i, b, w)
+		}
+	}
+
+	resp, err = svc.GetSavingsHistory(context.Background(), 2, service.TimeRange{}, proto.GetSavingsHistoryRequest_DAY, "RUB")
+	if err != nil || resp.Status != proto.GetSavingsResponse_NO_PURCHASES {
+		t.Errorf("user without purchases: %v, %v", resp, err)
+	}
+	resp, err = svc.GetSavingsHistory(context.Background(), 1, service.TimeRange{}, proto.GetSavingsHistoryRequest_Granularity(42), "RUB")
+	if err != nil || resp.Status != proto.GetSavingsResponse_INVALID_REQUEST {
+		t.Errorf("unknown granularity: %v, %v", resp, err)
+	}
+}
+
+func TestGetSavingsHistoryBucketsInUTC(t *testing.T) {
+	// 31 мая 20:00 UTC на сервере с UTC+10 - уже 1 июня, но интервал остаётся майским
+	local := time.Local
+	time.Local = time.FixedZone("UTC+10", 10*60*60)
+	defer func() { time.Local = local }()
+
+	store := database.NewMemoryStore(database.Event{Timestamp: day(time.May, 31, 20), UserID: 1, EventName: "buy",
+		Parameters: `{"amount":100,"payment_method":"card"}`})
+	rules, err := cashback.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	svc := service.NewMoneyService(store, rules, exchange.NewConverter(nil))
+
+	resp, err := svc.GetSavingsHistory(context.Background(), 1, service.TimeRange{}, proto.GetSavingsHistoryRequest_MONTH, "RUB")
+	if err != nil || len(resp.Buckets) != 1 {
+		t.Fatalf("GetSavingsHistory = %v, %v", resp, err)
+	}
+	if got, want := resp.Buckets[0].Start.AsTime(), day(time.May, 1, 0); !got.Equal(want) {
+		t.Errorf("bucket start = %v, want %v", got, want)
+	}
+}
+
+func TestGetSavingsHistoryMatchesGetSavings(t *testing.T) {
+	// 3% от 10.50 ₽ и от 52.50 ₸ (10.50 ₽ по 0.2) по отдельности округляются вверх: в рублях
+	// 0.32 + 0.32, а не 0.63 от общей суммы. История должна округлять так же, как GetSavings.
+	store := database.NewMemoryStore(
+		database.Event{Timestamp: day(time.May, 2, 12), UserID: 1, EventName: "buy", Parameters: `{"amount":10.5,"payment_method":"card"}`},
+		database.Event{Timestamp: day(time.May, 20, 12), UserID: 1, EventName: "buy", Parameters: `{"amount":52.5,"currency":"KZT","payment_method":"card"}`},
+	)
+	rules, err := cashback.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	rates, err := exchange.NewTable([]exchange.Rate{
+		{Day: time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC), Currency: "KZT", Rate: decimal.RequireFromString("0.2")},
+	})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	svc := service.NewMoneyService(store, rules, exchange.NewConverter(rates))
+	ctx := context.Background()
+
+	// обе покупки в мае, поэтому помесячная история из одного интервала
+	for _, tt := range []struct{ currency, want string }{{"KZT", "3.16"}, {"RUB", "0.64"}} {
+		savings, err := svc.GetSavings(ctx, 1, service.TimeRange{}, tt.currency)
+		if err != nil || savings.Status != proto.GetSavingsResponse_OK || amount(savings.Savings) != tt.want {
+			t.Fatalf("GetSavings(%s) = %v, %v; want %s", tt.currency, savings, err, tt.want)
+		}
+		history, err := svc.GetSavingsHistory(ctx, 1, service.TimeRange{}, proto.GetSavingsHistoryRequest_MONTH, tt.currency)
+		if err != nil || history.Status != proto.GetSavingsResponse_OK || len(history.Buckets) != 1 {
+			t.Fatalf("GetSavingsHistory(%s) = %v, %v", tt.currency, history, err)
+		}
+		if got := money.FromFloat(history.Buckets[0].Savings).String(); got != tt.want {
+			t.Errorf("%s: history savings = %s, GetSavings = %s", tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestGetCashbackTier(t *testing.T) {
+	svc := newTestService(t)
+
+	resp, err := svc.GetCashbackTier(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetCashbackTier: %v", err)
+	}
+	if resp.Status != proto.GetSavingsResponse_OK || resp.Level != 1 || resp.WalletPurchases != 1 || resp.OrdersToNextTier != 9 {
+		t.Errorf("tier = %v", resp)
+	}
+
+	resp, err = svc.GetCashbackTier(context.Background(), 3)
+	if err != nil || resp.Status != proto.GetSavingsResponse_USER_NOT_FOUND {
+		t.Errorf("unknown user: %v, %v", resp, err)
+	}
+
+	// без файла правил уровней нет: кэшбек ровно CASHBACK_PERCENT
+	rules, err := cashback.NewEngine("")
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	store := database.NewMemoryStore(database.Event{Timestamp: day(time.May, 2, 12), UserID: 1, EventName: "buy",
+		Parameters: `{"amount":100,"payment_method":"wallet"}`})
+	resp, err = service.NewMoneyService(store, rules, exchange.NewConverter(nil)).GetCashbackTier(context.Background(), 1)
+	if err != nil || resp.Level != 0 || resp.BonusPercent != 0 || resp.OrdersToNextTier != 0 {
+		t.Errorf("tier without tiers = %v, %v", resp, err)
+	}
+}
+
+func TestBatchGetSavings(t *testing.T) {
+	svc := newTestService(t)
+
+	resp, err := svc.BatchGetSavings(context.Background(), []int64{1, 2, 3, -1, 1}, service.TimeRange{}, "RUB")
+	if err != nil {
+		t.Fatalf("BatchGetSavings: %v", err)
+	}
+	want := []struct {
+		userID int64
+		status proto.GetSavingsResponse_Status
+	}{
+		{1, proto.GetSavingsResponse_OK},
+		{2, proto.GetSavingsResponse_NO_PURCHASES},
+		{3, proto.GetSavingsResponse_USER_NOT_FOUND},
+		{-1, proto.GetSavingsResponse_INVALID_REQUEST},
+	}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("results = %v, want %d", resp.Results, len(want))
+	}
+	for i, w := range want {
+		if r := resp.Results[i]; r.UserId != w.userID || r.Savings.Status != w.status {
+			t.Errorf("result %d = %d %v, want %d %v", i, r.UserId, r.Savings.Status, w.userID, w.status)
+		}
+	}
+}
+
+func TestStreamSavings(t *testing.T) {
+	svc := newTestService(t)
+
+	var got []int64
+	err := svc.StreamSavings(context.Background(), service.Cohort{NeverPaidWithWallet: true}, service.TimeRange{}, "RUB", 1,
+		func(u *proto.UserSavings) error {
+			got = append(got, u.UserId)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("StreamSavings: %v", err)
+	}
+	// пользователь 8 отменил свою единственную покупку кошельком
+	if !slices.Equal(got, []int64{2, 4, 6, 8}) {
+		t.Errorf("streamed users = %v, want [2 4 6 8]", got)
+	}
+
+	if err := svc.StreamSavings(context.Background(), service.Cohort{}, service.TimeRange{}, "RUB", -1,
+		func(*proto.UserSavings) error { return nil }); err != service.ErrInvalidPageSize {
+		t.Errorf("negative page size: err = %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ErrPeriodConflict = errors.New("нельзя одновременно задавать period и from/to")
+	ErrInvalidPeriod  = errors.New("неизвестный период")
+	ErrInvalidRange   = errors.New("начало периода должно быть раньше конца")
+)
+
+// TimeRange - полуинтервал [From, To) по времени события; нулевая граница - без ограничения
+type TimeRange = database.TimeRange
+
+// PeriodRequest - запрос, в котором можно задать период (GetSavingsRequest, GetSavingsHistoryRequest)
+type PeriodRequest interface {
+	GetFrom() *timestamppb.Timestamp
+	GetTo() *timestamppb.Timestamp
+	GetPeriod() proto.GetSavingsRequest_Period
+}
+
+// TimeRangeFromRequest вычисляет период из запроса. Готовые периоды отсчитываются от now
+// в его часовом поясе, поэтому "текущий месяц" зависит от TZ сервера.
+func TimeRangeFromRequest(req PeriodRequest, now time.Time) (TimeRange, error) {
+	hasBounds := req.GetFrom() != nil || req.GetTo() != nil
+
+	if req.GetPeriod() != proto.GetSavingsRequest_ALL_TIME {
+		if hasBounds {
+			return TimeRange{}, ErrPeriodConflict
+		}
+		return periodRange(req.GetPeriod(), now)
+	}
+
+	var r TimeRange
+	if req.GetFrom() != nil {
+		if err := req.GetFrom().CheckValid(); err != nil {
+			return TimeRange{}, err
+		}
+		r.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		if err := req.GetTo().CheckValid(); err != nil {
+			return TimeRange{}, err
+		}
+		r.To = req.GetTo().AsTime()
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+		return TimeRange{}, ErrInvalidRange
+	}
+	return r, nil
+}
+
+func periodRange(period proto.GetSavingsRequest_Period, now time.Time) (TimeRange, error) {
+	switch period {
+	case proto.GetSavingsRequest_LAST_7_DAYS:
+		return TimeRange{From: now.AddDate(0, 0, -7)}, nil
+	case proto.GetSavingsRequest_LAST_30_DAYS:
+		return TimeRange{From: now.AddDate(0, 0, -30)}, nil
+	case proto.GetSavingsRequest_CURRENT_MONTH:
+		return TimeRange{From: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())}, nil
+	case proto.GetSavingsRequest_CURRENT_YEAR:
+		return TimeRange{From: time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())}, nil
+	default:
+		return TimeRange{}, ErrInvalidPeriod
+	}
+}
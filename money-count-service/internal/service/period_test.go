@@ -0,0 +1,81 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Qwental/wb-money/internal/service"
+	"github.com/Qwental/wb-money/pkg/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTimeRangeFromRequest(t *testing.T) {
+	now := time.Date(2025, time.May, 17, 15, 30, 0, 0, time.UTC)
+	from := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.May, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		req     *proto.GetSavingsRequest
+		want    service.TimeRange
+		wantErr error
+	}{
+		{
+			name: "all time",
+			req:  &proto.GetSavingsRequest{UserId: 1},
+		},
+		{
+			name: "explicit bounds",
+			req:  &proto.GetSavingsRequest{UserId: 1, From: timestamppb.New(from), To: timestamppb.New(to)},
+			want: service.TimeRange{From: from, To: to},
+		},
+		{
+			name: "only upper bound",
+			req:  &proto.GetSavingsRequest{UserId: 1, To: timestamppb.New(to)},
+			want: service.TimeRange{To: to},
+		},
+		{
+			name:    "inverted bounds",
+			req:     &proto.GetSavingsRequest{UserId: 1, From: timestamppb.New(to), To: timestamppb.New(from)},
+			wantErr: service.ErrInvalidRange,
+		},
+		{
+			name: "last 30 days",
+			req:  &proto.GetSavingsRequest{UserId: 1, Period: proto.GetSavingsRequest_LAST_30_DAYS},
+			want: service.TimeRange{From: time.Date(2025, time.April, 17, 15, 30, 0, 0, time.UTC)},
+		},
+		{
+			name: "current month",
+			req:  &proto.GetSavingsRequest{UserId: 1, Period: proto.GetSavingsRequest_CURRENT_MONTH},
+			want: service.TimeRange{From: from},
+		},
+		{
+			name: "current year",
+			req:  &proto.GetSavingsRequest{UserId: 1, Period: proto.GetSavingsRequest_CURRENT_YEAR},
+			want: service.TimeRange{From: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "period with bounds",
+			req:     &proto.GetSavingsRequest{UserId: 1, Period: proto.GetSavingsRequest_LAST_7_DAYS, From: timestamppb.New(from)},
+			wantErr: service.ErrPeriodConflict,
+		},
+		{
+			name:    "unknown period",
+			req:     &proto.GetSavingsRequest{UserId: 1, Period: proto.GetSavingsRequest_Period(42)},
+			wantErr: service.ErrInvalidPeriod,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.TimeRangeFromRequest(tt.req, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) {
+				t.Errorf("range = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
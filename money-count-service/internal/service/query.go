@@ -0,0 +1,19 @@
+package service
+
+import (
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/pkg/proto"
+)
+
+// IsTimeout сообщает, что запрос к хранилищу прерван по времени, а не упал
+func IsTimeout(err error) bool {
+	return database.IsTimeout(err)
+}
+
+// dbFailure - статус и сообщение ответа при ошибке запроса: TIMEOUT, если не уложились во время, иначе DB_ERROR
+func dbFailure(err error, message string) (proto.GetSavingsResponse_Status, string) {
+	if IsTimeout(err) {
+		return proto.GetSavingsResponse_TIMEOUT, "Превышено время ожидания ответа базы данных"
+	}
+	return proto.GetSavingsResponse_DB_ERROR, message
+}
@@ -0,0 +1,31 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/Qwental/wb-money/internal/service"
+)
+
+func TestIsTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"context deadline", context.DeadlineExceeded, true},
+		{"wrapped deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), true},
+		{"max_execution_time exceeded", &clickhouse.Exception{Code: 159, Name: "TIMEOUT_EXCEEDED"}, true},
+		{"other exception", &clickhouse.Exception{Code: 60, Name: "UNKNOWN_TABLE"}, false},
+		{"client cancelled", context.Canceled, false},
+		{"network error", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := service.IsTimeout(tt.err); got != tt.want {
+			t.Errorf("%s: IsTimeout = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Qwental/wb-money/internal/database"
+	"github.com/Qwental/wb-money/pkg/models"
+	"github.com/Qwental/wb-money/pkg/proto"
+)
+
+// DefaultStreamPageSize - сколько пользователей StreamSavings берёт за один запрос, если клиент не указал
+const DefaultStreamPageSize = 500
+
+var ErrInvalidPageSize = fmt.Errorf("page_size должен быть от 0 до %d", MaxBatchUsers)
+
+// Cohort - фильтр пользователей для выгрузки экономии
+type Cohort = database.Cohort
+
+// CohortFromRequest переводит фильтр из запроса; nil означает всех пользователей
+func CohortFromRequest(filter *proto.CohortFilter) (Cohort, error) {
+	var c Cohort
+	if filter == nil {
+		return c, nil
+	}
+
+	for _, name := range filter.EventNames {
+		if strings.TrimSpace(name) == "" {
+			return Cohort{}, errors.New("пустое имя события в когорте")
+		}
+		if !models.EventName(name).Known() {
+			return Cohort{}, fmt.Errorf("%w в когорте: %q", models.ErrUnknownEvent, name)
+		}
+		c.EventNames = append(c.EventNames, models.EventName(name))
+	}
+	c.NeverPaidWithWallet = filter.NeverPaidWithWallet
+
+	if filter.EventsFrom != nil {
+		if err := filter.EventsFrom.CheckValid(); err != nil {
+			return Cohort{}, err
+		}
+		c.Events.From = filter.EventsFrom.AsTime()
+	}
+	if filter.EventsTo != nil {
+		if err := filter.EventsTo.CheckValid(); err != nil {
+			return Cohort{}, err
+		}
+		c.Events.To = filter.EventsTo.AsTime()
+	}
+	if !c.Events.From.IsZero() && !c.Events.To.IsZero() && !c.Events.From.Before(c.Events.To) {
+		return Cohort{}, ErrInvalidRange
+	}
+	return c, nil
+}
+
+// StreamSavings постранично выбирает пользователей когорты и отдаёт их экономию через send.
+// Между страницами проверяется ctx, поэтому отмена на стороне клиента останавливает выгрузку.
+func (s *MoneyService) StreamSavings(ctx context.Context, cohort Cohort, period TimeRange, currency string, pageSize int,
+	send func(*proto.UserSavings) error) error {
+	if pageSize < 0 || pageSize > MaxBatchUsers {
+		return ErrInvalidPageSize
+	}
+	if pageSize == 0 {
+		pageSize = DefaultStreamPageSize
+	}
+
+	rules := s.rules.Rules()
+
+	var lastUserID uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Страница может оказаться пустой, если никто из её пользователей не входит в когорту
+		userIDs, last, err := s.store.CohortPage(ctx, cohort, lastUserID, pageSize)
+		if err != nil {
+			return fmt.Errorf("выборка когорты после user_id %d: %w", lastUserID, err)
+		}
+		if last == 0 {
+			return nil
+		}
+
+		if len(userIDs) > 0 {
+			aggs, err := s.store.SavingsAggregates(ctx, rules, userIDs, period)
+			if err != nil {
+				return fmt.Errorf("агрегаты для страницы после user_id %d: %w", lastUserID, err)
+			}
+			for _, id := range userIDs {
+				savings := s.savingsResponse(ctx, id, rules, aggs[id], currency)
+				if err := send(&proto.UserSavings{UserId: int64(id), Savings: savings}); err != nil {
+					return err
+				}
+			}
+		}
+		lastUserID = last
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/Qwental/wb-money/internal/logging"
+	"github.com/Qwental/wb-money/pkg/proto"
+)
+
+func (s *MoneyService) GetCashbackTier(ctx context.Context, userID uint64) (*proto.GetCashbackTierResponse, error) {
+	if userID == 0 {
+		return &proto.GetCashbackTierResponse{
+			Status:  proto.GetSavingsResponse_INVALID_REQUEST,
+			Message: "User ID не может быть пустым",
+		}, nil
+	}
+
+	stats, err := s.store.UserStats(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("wallet stats query failed", logging.UserID(userID), slog.Any("error", err))
+		st, message := dbFailure(err, "Ошибка доступа к базе данных")
+		return &proto.GetCashbackTierResponse{Status: st, Message: message}, nil
+	}
+
+	if !stats.UserExists {
+		return &proto.GetCashbackTierResponse{
+			Status:  proto.GetSavingsResponse_USER_NOT_FOUND,
+			Message: fmt.Sprintf("Пользователь с ID %d не найден", userID),
+		}, nil
+	}
+
+	walletOrders := int(stats.WalletOrders)
+	rules := s.rules.Rules()
+	current, next := rules.TierFor(walletOrders)
+
+	response := &proto.GetCashbackTierResponse{
+		Status:          proto.GetSavingsResponse_OK,
+		Level:           int32(current.Level),
+		BonusPercent:    current.BonusPercent,
+		WalletPurchases: int32(walletOrders),
+	}
+	switch {
+	case len(rules.Tiers) == 0:
+		response.Message = "Уровни кэшбека не настроены"
+	case next != nil:
+		response.OrdersToNextTier = int32(next.MinWalletOrders - walletOrders)
+		response.NextBonusPercent = next.BonusPercent
+		response.Message = fmt.Sprintf("Ещё %d покупок WB-кошельком до +%g%% кэшбека",
+			response.OrdersToNextTier, next.BonusPercent)
+	default:
+		response.Message = "Достигнут максимальный уровень кэшбека"
+	}
+
+	return response, nil
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName         = "github.com/Qwental/wb-money"
+	defaultServiceName = "money-service"
+)
+
+// Setup включает W3C trace context и, если задан OTLP endpoint (OTEL_EXPORTER_OTLP_ENDPOINT
+// или OTEL_EXPORTER_OTLP_TRACES_ENDPOINT), экспорт спанов по OTLP/gRPC. Остальные настройки
+// экспортёра, сэмплера и ресурса берутся из стандартных переменных OTEL_*.
+// Возвращает функцию, которая досылает накопленные спаны при остановке; enabled - включён ли экспорт.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, enabled bool, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	noop := func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return noop, false, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return noop, false, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(defaultServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return noop, false, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, true, nil
+}
+
+// HTTPHandler создаёт спан на каждый HTTP-запрос gRPC-Web и передаёт его дальше в заголовке
+// traceparent: обёртка превращает заголовки в gRPC metadata, и спан вызова становится дочерним
+// к HTTP-спану, а не к спану браузера. Служебные пути (skip) не трассируются.
+func HTTPHandler(next http.Handler, skip ...string) http.Handler {
+	inject := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otel.GetTextMapPropagator().Inject(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r)
+	})
+	return otelhttp.NewHandler(inject, "grpc-web",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			for _, path := range skip {
+				if r.URL.Path == path {
+					return false
+				}
+			}
+			return true
+		}),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+}
+
+// StartQuery открывает спан запроса к ClickHouse; в атрибуты попадает SQL без литералов
+func StartQuery(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "clickhouse "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameClickhouse,
+			semconv.DBQuerySummary(name),
+			semconv.DBQueryText(SanitizeSQL(query)),
+		),
+	)
+}
+
+// EndQuery закрывает спан запроса, отмечая ошибку
+func EndQuery(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^'\\]|\\.)*'`)
+	numberLiteral  = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	spaceSequences = regexp.MustCompile(`\s+`)
+)
+
+// SanitizeSQL заменяет строковые и числовые литералы на ? и схлопывает пробелы.
+// Значения пользователя передаются параметрами и в текст запроса не попадают,
+// но литералы в шаблоне (методы оплаты, имена событий) тоже не нужны в трейсах.
+func SanitizeSQL(query string) string {
+	query = stringLiteral.ReplaceAllString(query, "?")
+	query = numberLiteral.ReplaceAllString(query, "?")
+	return strings.TrimSpace(spaceSequences.ReplaceAllString(query, " "))
+}
@@ -0,0 +1,108 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Qwental/wb-money/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupInMemory подменяет глобальный провайдер на запись спанов в память
+func setupInMemory(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return exporter
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"SELECT  *\n\tFROM product_events WHERE event_name = 'buy'", "SELECT * FROM product_events WHERE event_name = ?"},
+		{"countIf(payment_method = 'wallet') AS n, toUInt64(1)", "countIf(payment_method = ?) AS n, toUInt64(?)"},
+		{"WHERE user_id IN (?, ?) AND amount >= 1000.5", "WHERE user_id IN (?, ?) AND amount >= ?"},
+		{`WHERE s = 'it\'s'`, "WHERE s = ?"},
+	}
+	for _, tt := range tests {
+		if got := tracing.SanitizeSQL(tt.in); got != tt.want {
+			t.Errorf("SanitizeSQL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuerySpan(t *testing.T) {
+	exporter := setupInMemory(t)
+
+	_, span := tracing.StartQuery(context.Background(), "wallet_stats", "SELECT 1 WHERE name = 'secret'")
+	tracing.EndQuery(span, errors.New("timeout"))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	s := spans[0]
+	if s.Name != "clickhouse wallet_stats" || s.SpanKind != trace.SpanKindClient {
+		t.Errorf("span = %q kind %v", s.Name, s.SpanKind)
+	}
+	if s.Status.Code != codes.Error {
+		t.Errorf("status = %v, want Error", s.Status.Code)
+	}
+	for _, kv := range s.Attributes {
+		if kv.Key == attribute.Key("db.query.text") && strings.Contains(kv.Value.AsString(), "secret") {
+			t.Errorf("query text not sanitized: %q", kv.Value.AsString())
+		}
+	}
+}
+
+func TestHTTPHandlerPropagatesTraceContext(t *testing.T) {
+	exporter := setupInMemory(t)
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var forwarded string
+	h := tracing.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("traceparent")
+	}), "/readyz")
+
+	req := httptest.NewRequest(http.MethodPost, "/money_service.MoneyService/GetSavings", nil)
+	req.Header.Set("traceparent", parent)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	httpSpan := spans[0]
+	if got := httpSpan.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want the one from traceparent", got)
+	}
+	// дальше по цепочке уходит уже HTTP-спан, чтобы спан gRPC-вызова стал его дочерним
+	if !strings.Contains(forwarded, httpSpan.SpanContext.SpanID().String()) {
+		t.Errorf("forwarded traceparent = %q, want span id %s", forwarded, httpSpan.SpanContext.SpanID())
+	}
+
+	exporter.Reset()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if n := len(exporter.GetSpans()); n != 0 {
+		t.Errorf("skipped path produced %d spans", n)
+	}
+}
@@ -0,0 +1,83 @@
+// Package models описывает события таблицы product_events: имена событий,
+// параметры каждого типа из колонки parameters, их разбор и проверку.
+// Пакет общий для money-service, генератора моковых данных и аналитики по кошельку.
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// EventName - значение колонки event_name
+type EventName string
+
+const (
+	EventOpenApp        EventName = "open_app"        // пользователь открыл приложение
+	EventCart           EventName = "cart"            // пользователь перешёл в корзину
+	EventPaymentMethods EventName = "payment_methods" // пользователь открыл выбор способа оплаты
+	EventBuy            EventName = "buy"             // покупка
+	EventRefund         EventName = "refund"          // возврат заказа после получения
+	EventCancel         EventName = "cancel"          // отмена заказа до получения
+)
+
+// EventNames - все известные события в порядке воронки, затем отмены заказа
+var EventNames = []EventName{EventOpenApp, EventCart, EventPaymentMethods, EventBuy, EventRefund, EventCancel}
+
+// Known сообщает, известно ли событие
+func (n EventName) Known() bool {
+	switch n {
+	case EventOpenApp, EventCart, EventPaymentMethods, EventBuy, EventRefund, EventCancel:
+		return true
+	}
+	return false
+}
+
+// Cancels сообщает, что событие отменяет покупку заказа: такие покупки не входят в расчёты
+func (n EventName) Cancels() bool {
+	return n == EventRefund || n == EventCancel
+}
+
+// PaymentMethod - способ оплаты покупки
+type PaymentMethod string
+
+const (
+	PaymentWallet PaymentMethod = "wallet" // WB-кошелёк
+	PaymentCard   PaymentMethod = "card"
+	PaymentCash   PaymentMethod = "cash"
+)
+
+// PaymentMethods - все известные способы оплаты
+var PaymentMethods = []PaymentMethod{PaymentWallet, PaymentCard, PaymentCash}
+
+// Known сообщает, известен ли способ оплаты
+func (m PaymentMethod) Known() bool {
+	switch m {
+	case PaymentWallet, PaymentCard, PaymentCash:
+		return true
+	}
+	return false
+}
+
+// DefaultCurrency - валюта событий, в которых она не указана
+const DefaultCurrency = "RUB"
+
+// currencyCode - трёхбуквенный код валюты ISO 4217
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrency проверяет, что код валюты имеет вид ISO 4217
+func ValidCurrency(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+// Event - строка таблицы product_events
+type Event struct {
+	Timestamp  time.Time
+	UserID     uint64
+	EventName  EventName
+	Parameters string // JSON, как он лежит в ClickHouse; может быть невалидным
+}
+
+// Params разбирает параметры события в заданном режиме
+func (e Event) Params(mode Mode) (Params, error) {
+	return ParseParams(e.EventName, e.Parameters, mode)
+}
@@ -0,0 +1,88 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Qwental/wb-money/pkg/models"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   models.EventName
+		raw     string
+		mode    models.Mode
+		want    models.Params
+		wantErr error
+	}{
+		{"buy", models.EventBuy, `{"amount": 120.5, "currency": "RUB", "n_goods": 2, "payment_method": "wallet"}`, models.Strict,
+			&models.BuyParams{Amount: 12050, Currency: "RUB", NGoods: 2, PaymentMethod: models.PaymentWallet}, nil},
+		{"default currency", models.EventCart, `{"total_amount": 10, "n_goods": 1}`, models.Strict,
+			&models.CartParams{TotalAmount: 1000, Currency: "RUB", NGoods: 1}, nil},
+		{"payment methods", models.EventPaymentMethods, `{"default_method": "card"}`, models.Strict,
+			&models.PaymentMethodsParams{DefaultMethod: models.PaymentCard}, nil},
+		{"refund", models.EventRefund, `{"order_id": "1042-7", "reason": "size"}`, models.Strict,
+			&models.RefundParams{OrderID: "1042-7", Reason: "size"}, nil},
+		{"cancel without order", models.EventCancel, `{}`, models.Strict, nil, models.ErrMissingOrderID},
+		{"malformed", models.EventBuy, `{"amount":`, models.Lenient, nil, models.ErrMalformed},
+		{"unknown event", "return", `{}`, models.Lenient, nil, models.ErrUnknownEvent},
+		{"strict unknown field", models.EventOpenApp, `{"platform": "ios", "os": "17"}`, models.Strict, nil, models.ErrInvalidParams},
+		{"strict wrong type", models.EventBuy, `{"amount": "100", "payment_method": "card"}`, models.Strict, nil, models.ErrInvalidParams},
+		{"strict not object", models.EventBuy, `[]`, models.Strict, nil, models.ErrInvalidParams},
+		{"negative amount", models.EventBuy, `{"amount": -1, "payment_method": "card"}`, models.Strict, nil, models.ErrInvalidAmount},
+		{"bad currency", models.EventCart, `{"total_amount": 1, "currency": "rub"}`, models.Strict, nil, models.ErrInvalidCurrency},
+		{"unknown method", models.EventPaymentMethods, `{"default_method": "crypto"}`, models.Strict, nil, models.ErrUnknownPaymentMethod},
+		{"lenient wrong type and extra field", models.EventBuy, `{"amount": "100", "payment_method": "card", "extra": 1}`, models.Lenient,
+			&models.BuyParams{Currency: "RUB", PaymentMethod: models.PaymentCard}, nil},
+		{"lenient skips validation", models.EventBuy, `{"amount": -5, "payment_method": "crypto"}`, models.Lenient,
+			&models.BuyParams{Amount: -500, Currency: "RUB", PaymentMethod: "crypto"}, nil},
+		{"lenient not object", models.EventOpenApp, `"ios"`, models.Lenient, &models.OpenAppParams{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := models.ParseParams(tt.event, tt.raw, tt.mode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+			if got.EventName() != tt.event {
+				t.Errorf("EventName() = %q, want %q", got.EventName(), tt.event)
+			}
+			switch want := tt.want.(type) {
+			case *models.BuyParams:
+				if *got.(*models.BuyParams) != *want {
+					t.Errorf("params = %+v, want %+v", got, want)
+				}
+			case *models.CartParams:
+				if *got.(*models.CartParams) != *want {
+					t.Errorf("params = %+v, want %+v", got, want)
+				}
+			case *models.PaymentMethodsParams:
+				if *got.(*models.PaymentMethodsParams) != *want {
+					t.Errorf("params = %+v, want %+v", got, want)
+				}
+			case *models.RefundParams:
+				if *got.(*models.RefundParams) != *want {
+					t.Errorf("params = %+v, want %+v", got, want)
+				}
+			case *models.OpenAppParams:
+				if *got.(*models.OpenAppParams) != *want {
+					t.Errorf("params = %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuyParamsValidateJoinsErrors(t *testing.T) {
+	p := models.BuyParams{Amount: -1, Currency: "rubles", NGoods: -2, PaymentMethod: "barter"}
+	err := p.Validate()
+	for _, want := range []error{models.ErrInvalidAmount, models.ErrInvalidCurrency, models.ErrInvalidCount, models.ErrUnknownPaymentMethod} {
+		if !errors.Is(err, want) {
+			t.Errorf("Validate() = %v, want it to contain %v", err, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Qwental/wb-money/pkg/money"
+)
+
+// Ошибки проверки параметров; оборачиваются с именем поля
+var (
+	ErrInvalidAmount        = errors.New("сумма не может быть отрицательной")
+	ErrInvalidCount         = errors.New("количество товаров не может быть отрицательным")
+	ErrInvalidCurrency      = errors.New("код валюты должен состоять из трёх заглавных латинских букв")
+	ErrUnknownPaymentMethod = errors.New("неизвестный способ оплаты")
+	ErrMissingOrderID       = errors.New("не указан заказ")
+)
+
+// Params - параметры события одного из известных типов
+type Params interface {
+	// EventName возвращает событие, к которому относятся параметры
+	EventName() EventName
+	// Validate проверяет значения параметров
+	Validate() error
+	// normalize заполняет значения по умолчанию
+	normalize()
+}
+
+// OpenAppParams - параметры события open_app
+type OpenAppParams struct {
+	Platform string `json:"platform"`
+	Region   string `json:"region"`
+}
+
+// CartParams - параметры события cart
+type CartParams struct {
+	TotalAmount money.Amount `json:"total_amount"`
+	Currency    string       `json:"currency"`
+	NGoods      int          `json:"n_goods"`
+	GoodsList   string       `json:"goods_list,omitempty"`
+}
+
+// PaymentMethodsParams - параметры события payment_methods
+type PaymentMethodsParams struct {
+	DefaultMethod PaymentMethod `json:"default_method"`
+}
+
+// BuyParams - параметры события buy
+type BuyParams struct {
+	Amount        money.Amount  `json:"amount"`
+	Currency      string        `json:"currency"`
+	NGoods        int           `json:"n_goods"`
+	PaymentMethod PaymentMethod `json:"payment_method"`
+	Category      string        `json:"category,omitempty"`
+	OrderID       string        `json:"order_id,omitempty"` // нужен, чтобы покупку можно было вернуть или отменить
+}
+
+// RefundParams - параметры события refund: возвращён весь заказ OrderID
+type RefundParams struct {
+	OrderID string `json:"order_id"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// CancelParams - параметры события cancel: отменён весь заказ OrderID
+type CancelParams struct {
+	OrderID string `json:"order_id"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (*OpenAppParams) EventName() EventName        { return EventOpenApp }
+func (*CartParams) EventName() EventName           { return EventCart }
+func (*PaymentMethodsParams) EventName() EventName { return EventPaymentMethods }
+func (*BuyParams) EventName() EventName            { return EventBuy }
+func (*RefundParams) EventName() EventName         { return EventRefund }
+func (*CancelParams) EventName() EventName         { return EventCancel }
+
+func (*OpenAppParams) Validate() error { return nil }
+
+func (p *CartParams) Validate() error {
+	return errors.Join(
+		validateAmount("total_amount", p.TotalAmount),
+		validateCurrency(p.Currency),
+		validateCount(p.NGoods),
+	)
+}
+
+func (p *PaymentMethodsParams) Validate() error {
+	return validatePaymentMethod("default_method", p.DefaultMethod)
+}
+
+func (p *BuyParams) Validate() error {
+	return errors.Join(
+		validateAmount("amount", p.Amount),
+		validateCurrency(p.Currency),
+		validateCount(p.NGoods),
+		validatePaymentMethod("payment_method", p.PaymentMethod),
+	)
+}
+
+func (p *RefundParams) Validate() error { return validateOrderID(p.OrderID) }
+func (p *CancelParams) Validate() error { return validateOrderID(p.OrderID) }
+
+func (*OpenAppParams) normalize()        {}
+func (*PaymentMethodsParams) normalize() {}
+func (*RefundParams) normalize()         {}
+func (*CancelParams) normalize()         {}
+
+func (p *CartParams) normalize() {
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
+	}
+}
+
+func (p *BuyParams) normalize() {
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
+	}
+}
+
+func validateAmount(field string, v money.Amount) error {
+	if v < 0 {
+		return fmt.Errorf("%s = %v: %w", field, v, ErrInvalidAmount)
+	}
+	return nil
+}
+
+func validateCount(v int) error {
+	if v < 0 {
+		return fmt.Errorf("n_goods = %d: %w", v, ErrInvalidCount)
+	}
+	return nil
+}
+
+func validateCurrency(code string) error {
+	if !ValidCurrency(code) {
+		return fmt.Errorf("currency = %q: %w", code, ErrInvalidCurrency)
+	}
+	return nil
+}
+
+func validateOrderID(id string) error {
+	if id == "" {
+		return fmt.Errorf("order_id: %w", ErrMissingOrderID)
+	}
+	return nil
+}
+
+func validatePaymentMethod(field string, m PaymentMethod) error {
+	if !m.Known() {
+		return fmt.Errorf("%s = %q: %w", field, m, ErrUnknownPaymentMethod)
+	}
+	return nil
+}
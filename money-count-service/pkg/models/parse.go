@@ -0,0 +1,149 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Mode - режим разбора parameters
+type Mode int
+
+const (
+	// Lenient разбирает parameters так же, как запросы к ClickHouse через JSONExtract*:
+	// отсутствующее поле или поле не того типа даёт нулевое значение, лишние поля игнорируются,
+	// значения не проверяются. Ошибка только для невалидного JSON.
+	Lenient Mode = iota
+	// Strict требует JSON-объект только с известными полями нужных типов
+	// и проверяет значения через Validate
+	Strict
+)
+
+func (m Mode) String() string {
+	if m == Strict {
+		return "strict"
+	}
+	return "lenient"
+}
+
+var (
+	// ErrMalformed - parameters не являются валидным JSON
+	ErrMalformed = errors.New("parameters не являются валидным JSON")
+	// ErrInvalidParams - в строгом режиме JSON не соответствует схеме события
+	ErrInvalidParams = errors.New("parameters не соответствуют схеме события")
+	// ErrUnknownEvent - для события нет схемы параметров
+	ErrUnknownEvent = errors.New("неизвестное событие")
+)
+
+// newParams возвращает пустые параметры события
+func newParams(name EventName) (Params, error) {
+	switch name {
+	case EventOpenApp:
+		return &OpenAppParams{}, nil
+	case EventCart:
+		return &CartParams{}, nil
+	case EventPaymentMethods:
+		return &PaymentMethodsParams{}, nil
+	case EventBuy:
+		return &BuyParams{}, nil
+	case EventRefund:
+		return &RefundParams{}, nil
+	case EventCancel:
+		return &CancelParams{}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownEvent, name)
+}
+
+// ParseParams разбирает parameters события. Пустые валюты заменяются на DefaultCurrency.
+func ParseParams(name EventName, raw string, mode Mode) (Params, error) {
+	p, err := newParams(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(raw, p, mode); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParseBuy разбирает parameters покупки
+func ParseBuy(raw string, mode Mode) (BuyParams, error) {
+	var p BuyParams
+	err := decode(raw, &p, mode)
+	return p, err
+}
+
+// ParseCancelledOrder разбирает parameters события refund или cancel и возвращает заказ,
+// покупку по которому нужно не учитывать
+func ParseCancelledOrder(name EventName, raw string, mode Mode) (string, error) {
+	p, err := ParseParams(name, raw, mode)
+	if err != nil {
+		return "", err
+	}
+	switch p := p.(type) {
+	case *RefundParams:
+		return p.OrderID, nil
+	case *CancelParams:
+		return p.OrderID, nil
+	}
+	return "", fmt.Errorf("%w: %q не отменяет заказ", ErrUnknownEvent, name)
+}
+
+func decode(raw string, p Params, mode Mode) error {
+	if !json.Valid([]byte(raw)) {
+		return ErrMalformed
+	}
+	if mode == Strict {
+		if err := decodeStrict(raw, p); err != nil {
+			return fmt.Errorf("%s: %w: %v", p.EventName(), ErrInvalidParams, err)
+		}
+		p.normalize()
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", p.EventName(), err)
+		}
+		return nil
+	}
+	decodeLenient(raw, p)
+	p.normalize()
+	return nil
+}
+
+func decodeStrict(raw string, p Params) error {
+	if !strings.HasPrefix(strings.TrimSpace(raw), "{") {
+		return errors.New("ожидается JSON-объект")
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("после JSON-объекта есть лишние данные")
+	}
+	return nil
+}
+
+// decodeLenient заполняет поля структуры по json-тегам, пропуская значения не того типа
+func decodeLenient(raw string, p Params) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal([]byte(raw), &fields) != nil {
+		return // не объект: все поля нулевые, как у JSONExtract*
+	}
+	v := reflect.ValueOf(p).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		value, ok := fields[name]
+		if !ok || bytes.Equal(value, []byte("null")) {
+			continue
+		}
+		field := reflect.New(t.Field(i).Type)
+		if json.Unmarshal(value, field.Interface()) == nil {
+			v.Field(i).Set(field.Elem())
+		}
+	}
+}
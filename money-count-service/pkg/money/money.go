@@ -0,0 +1,106 @@
+// Package money - точная денежная арифметика. Суммы хранятся целым числом минимальных единиц
+// валюты (копеек), проценты применяются в десятичной арифметике, округление всегда явное:
+//
+//   - сумма из события приводится к копейкам к ближайшему значению, половина - к чётному,
+//     так же как round(x * 100) в запросах к ClickHouse;
+//   - кэшбек считается от суммы покупок и округляется до копейки, половина - от нуля (Percent).
+package money
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+// Scale - знаков после запятой у сумм; у всех валют, с которыми работает сервис, их два
+const Scale = 2
+
+// Amount - сумма в минимальных единицах валюты (копейках для рубля)
+type Amount int64
+
+var (
+	ErrNotNumber = errors.New("сумма должна быть числом")
+	ErrOverflow  = errors.New("сумма не помещается в int64 копеек")
+)
+
+var (
+	maxAmount = decimal.NewFromInt(math.MaxInt64)
+	minAmount = decimal.NewFromInt(math.MinInt64)
+)
+
+// FromDecimal переводит сумму в основных единицах в копейки, лишние знаки округляются к чётному
+func FromDecimal(d decimal.Decimal) (Amount, error) {
+	minor := d.Shift(Scale).RoundBank(0)
+	if minor.GreaterThan(maxAmount) || minor.LessThan(minAmount) {
+		return 0, fmt.Errorf("%s: %w", d, ErrOverflow)
+	}
+	return Amount(minor.IntPart()), nil
+}
+
+// FromFloat переводит сумму в основных единицах в копейки так же, как round(x * 100) в ClickHouse.
+// Нужен для значений из конфигурации; суммы событий разбираются точно через Parse.
+func FromFloat(v float64) Amount {
+	return Amount(math.RoundToEven(v * math.Pow10(Scale)))
+}
+
+// Parse разбирает десятичную запись суммы в основных единицах: "1234.56", "1e3"
+func Parse(s string) (Amount, error) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return 0, err
+	}
+	return FromDecimal(d)
+}
+
+// Decimal возвращает сумму в основных единицах
+func (a Amount) Decimal() decimal.Decimal {
+	return decimal.New(int64(a), -Scale)
+}
+
+// Float64 - ближайшее к сумме число float64 в основных единицах для полей double
+func (a Amount) Float64() float64 {
+	f, _ := a.Decimal().Float64()
+	return f
+}
+
+// String форматирует сумму с двумя знаками после запятой
+func (a Amount) String() string {
+	return a.Decimal().StringFixed(Scale)
+}
+
+// Units раскладывает сумму как google.type.Money: целые единицы и нано-единицы одного знака
+func (a Amount) Units() (units int64, nanos int32) {
+	const nanosPerMinor = 1_000_000_000 / 100
+	return int64(a) / 100, int32(int64(a)%100) * nanosPerMinor
+}
+
+// Percent возвращает percent процентов от суммы, округлённые до копейки (половина - от нуля)
+func Percent(a Amount, percent float64) Amount {
+	v := a.Decimal().Mul(decimal.NewFromFloat(percent)).Div(decimal.NewFromInt(100))
+	return Amount(v.Shift(Scale).Round(0).IntPart())
+}
+
+// UnmarshalJSON разбирает JSON-число без потери точности; строка с числом считается ошибкой,
+// как и в JSONExtractFloat, который для неё возвращает 0
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		return ErrNotNumber
+	}
+	v, err := Parse(string(b))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// MarshalJSON записывает сумму JSON-числом в основных единицах
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
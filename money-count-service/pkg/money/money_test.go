@@ -0,0 +1,93 @@
+package money_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Qwental/wb-money/pkg/money"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    money.Amount
+		wantErr bool
+	}{
+		{"1234.56", 123456, false},
+		{"0.1", 10, false},
+		{"1e3", 100000, false},
+		{"-2.5", -250, false},
+		{"0.125", 12, false}, // половина - к чётному
+		{"0.135", 14, false},
+		{"99999999999999999999", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := money.Parse(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount  money.Amount
+		percent float64
+		want    money.Amount
+	}{
+		{100000, 3, 3000},
+		{1, 50, 1},   // 0,5 копейки - от нуля
+		{-1, 50, -1}, // и для отрицательных
+		{333, 3, 10}, // 9,99 копейки
+		{1050, 4.5, 47},
+		{10, 0.1, 0},
+	}
+	for _, tt := range tests {
+		if got := money.Percent(tt.amount, tt.percent); got != tt.want {
+			t.Errorf("Percent(%d, %v) = %d, want %d", tt.amount, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestSumIsExact(t *testing.T) {
+	// 0.1 + 0.2 в float64 даёт 0.30000000000000004, в копейках ошибки нет
+	var total money.Amount
+	for range 1000 {
+		total += money.FromFloat(0.1) + money.FromFloat(0.2)
+	}
+	if total.String() != "300.00" || total.Float64() != 300 {
+		t.Errorf("total = %s", total)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	tests := []struct {
+		amount money.Amount
+		units  int64
+		nanos  int32
+	}{
+		{123456, 1234, 560_000_000},
+		{-150, -1, -500_000_000},
+		{7, 0, 70_000_000},
+	}
+	for _, tt := range tests {
+		if units, nanos := tt.amount.Units(); units != tt.units || nanos != tt.nanos {
+			t.Errorf("Units(%d) = %d, %d; want %d, %d", tt.amount, units, nanos, tt.units, tt.nanos)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct{ Amount money.Amount }
+	if err := json.Unmarshal([]byte(`{"Amount": 10.05}`), &v); err != nil || v.Amount != 1005 {
+		t.Errorf("Unmarshal number = %d, %v", v.Amount, err)
+	}
+	if err := json.Unmarshal([]byte(`{"Amount": "10.05"}`), &v); !errors.Is(err, money.ErrNotNumber) {
+		t.Errorf("Unmarshal string: err = %v", err)
+	}
+	if b, err := json.Marshal(v); err != nil || string(b) != `{"Amount":10.05}` {
+		t.Errorf("Marshal = %s, %v", b, err)
+	}
+}
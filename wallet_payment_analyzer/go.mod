@@ -2,7 +2,10 @@ module github.com/Qwental/wallet_payment_analyzer
 
 go 1.24.2
 
-require github.com/ClickHouse/clickhouse-go/v2 v2.36.0
+require (
+	github.com/ClickHouse/clickhouse-go/v2 v2.36.0
+	github.com/Qwental/wb-money v0.0.0
+)
 
 require (
 	github.com/ClickHouse/ch-go v0.66.0 // indirect
@@ -20,3 +23,8 @@ require (
 	golang.org/x/sys v0.33.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+// Общие пакеты pkg/models и pkg/money из money-count-service. Они вместе с остальными
+// зависимостями лежат в vendor/, чтобы модуль собирался без соседнего каталога;
+// после изменения pkg в money-count-service выполнить go mod vendor
+replace github.com/Qwental/wb-money => ../money-count-service
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/Qwental/wb-money/pkg/models"
+	"maps"
 	"net/http"
 	"os"
 )
@@ -14,6 +16,86 @@ type AnalyticsResult struct {
 	WalletPurchaseShare       float64 `json:"wallet_purchase_share"`
 }
 
+// walletShares - пользователи, по которым считаются доли кошелька
+type walletShares struct {
+	paymentMethods map[uint64]bool // открывали выбор способа оплаты
+	walletDefault  map[uint64]bool // хотя бы раз с кошельком по умолчанию
+	openedApp      map[uint64]bool // открывали приложение
+	walletBuyers   map[uint64]bool // покупали кошельком без order_id
+	walletOrders   map[order]bool  // заказы, оплаченные кошельком
+	cancelled      map[order]bool  // возвращённые и отменённые заказы
+}
+
+// order - заказ пользователя: order_id уникален только в паре с user_id
+type order struct {
+	userID  uint64
+	orderID string
+}
+
+func newWalletShares() *walletShares {
+	return &walletShares{
+		paymentMethods: map[uint64]bool{},
+		walletDefault:  map[uint64]bool{},
+		openedApp:      map[uint64]bool{},
+		walletBuyers:   map[uint64]bool{},
+		walletOrders:   map[order]bool{},
+		cancelled:      map[order]bool{},
+	}
+}
+
+// add учитывает событие. Параметры разбираются в нестрогом режиме:
+// событие с невалидным JSON считается событием без параметров
+func (s *walletShares) add(e models.Event) {
+	params, _ := e.Params(models.Lenient)
+	switch e.EventName {
+	case models.EventOpenApp:
+		s.openedApp[e.UserID] = true
+	case models.EventPaymentMethods:
+		s.paymentMethods[e.UserID] = true
+		if p, ok := params.(*models.PaymentMethodsParams); ok && p.DefaultMethod == models.PaymentWallet {
+			s.walletDefault[e.UserID] = true
+		}
+	case models.EventBuy:
+		if p, ok := params.(*models.BuyParams); ok && p.PaymentMethod == models.PaymentWallet {
+			if p.OrderID == "" {
+				s.walletBuyers[e.UserID] = true
+			} else {
+				s.walletOrders[order{e.UserID, p.OrderID}] = true
+			}
+		}
+	case models.EventRefund, models.EventCancel:
+		if orderID, err := models.ParseCancelledOrder(e.EventName, e.Parameters, models.Lenient); err == nil && orderID != "" {
+			s.cancelled[order{e.UserID, orderID}] = true
+		}
+	}
+}
+
+// buyers возвращает пользователей, у которых есть покупка кошельком, не отменённая и не возвращённая.
+// Отмена может прийти в любом порядке относительно покупки, поэтому заказы сверяются после чтения всех событий
+func (s *walletShares) buyers() map[uint64]bool {
+	buyers := maps.Clone(s.walletBuyers)
+	for o := range s.walletOrders {
+		if !s.cancelled[o] {
+			buyers[o.userID] = true
+		}
+	}
+	return buyers
+}
+
+// share - доля пользователей of, которые есть и в part
+func share(part, of map[uint64]bool) float64 {
+	if len(of) == 0 {
+		return 0
+	}
+	n := 0
+	for id := range part {
+		if of[id] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(of))
+}
+
 func getAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 	conn, err := clickhouse.Open(&clickhouse.Options{
 		Addr: []string{"localhost:9000"},
@@ -31,45 +113,38 @@ func getAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 
 	ctx := context.Background()
 
-	query1 := `
-		SELECT
-			COUNT(DISTINCT CASE WHEN JSONExtractString(parameters, 'default_method') = 'wallet' THEN user_id END) /
-			COUNT(DISTINCT user_id) AS wallet_payment_methods_share
+	// События разбираются общей моделью событий models, как их пишет генератор моковых данных
+	rows, err := conn.Query(ctx, `
+		SELECT user_id, event_name, parameters
 		FROM product_events
-		WHERE event_name = 'payment_methods'
-	`
-
-	var walletPaymentMethodsShare float64
-	err = conn.QueryRow(ctx, query1).Scan(&walletPaymentMethodsShare)
+		WHERE event_name IN (?, ?, ?, ?, ?)
+	`, string(models.EventOpenApp), string(models.EventPaymentMethods), string(models.EventBuy),
+		string(models.EventRefund), string(models.EventCancel))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to execute query 1: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to query events: %v", err), http.StatusInternalServerError)
 		return
 	}
+	defer rows.Close()
 
-	query2 := `
-		SELECT
-			COUNT(DISTINCT CASE WHEN buy.user_id IS NOT NULL THEN buy.user_id END) /
-			COUNT(DISTINCT open_app.user_id) AS wallet_purchase_share
-		FROM
-			(SELECT DISTINCT user_id FROM product_events WHERE event_name = 'open_app') AS open_app
-		LEFT JOIN
-			(SELECT DISTINCT user_id
-			 FROM product_events
-			 WHERE event_name = 'buy'
-			 AND JSONExtractString(parameters, 'payment_method') = 'wallet') AS buy
-		ON open_app.user_id = buy.user_id
-	`
-
-	var walletPurchaseShare float64
-	err = conn.QueryRow(ctx, query2).Scan(&walletPurchaseShare)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to execute query 2: %v", err), http.StatusInternalServerError)
+	shares := newWalletShares()
+	for rows.Next() {
+		var event models.Event
+		var name string
+		if err := rows.Scan(&event.UserID, &name, &event.Parameters); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read events: %v", err), http.StatusInternalServerError)
+			return
+		}
+		event.EventName = models.EventName(name)
+		shares.add(event)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read events: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	result := AnalyticsResult{
-		WalletPaymentMethodsShare: walletPaymentMethodsShare,
-		WalletPurchaseShare:       walletPurchaseShare,
+		WalletPaymentMethodsShare: share(shares.walletDefault, shares.paymentMethods),
+		WalletPurchaseShare:       share(shares.buyers(), shares.openedApp),
 	}
 
 	w.Header().Set("Content-Type", "application/json")